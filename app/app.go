@@ -2,28 +2,49 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"github.com/bytepowered/assert"
 	"github.com/fluxproxy/fluxproxy"
 	"github.com/fluxproxy/fluxproxy/feature"
 	"github.com/fluxproxy/fluxproxy/feature/authenticator"
+	"github.com/fluxproxy/fluxproxy/feature/dialer"
 	"github.com/fluxproxy/fluxproxy/feature/listener"
+	"github.com/fluxproxy/fluxproxy/feature/resolver"
+	"github.com/fluxproxy/fluxproxy/feature/router"
 	"github.com/fluxproxy/fluxproxy/feature/ruleset"
 	"github.com/fluxproxy/fluxproxy/helper"
 	"github.com/fluxproxy/fluxproxy/net"
 	"github.com/sirupsen/logrus"
 	stdnet "net"
 	"net/http"
+	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
 const (
-	RunServerModeAuto  string = "auto"
-	RunServerModeHttp  string = "http"
-	RunServerModeSocks string = "socks"
+	RunServerModeAuto        string = "auto"
+	RunServerModeHttp        string = "http"
+	RunServerModeSocks       string = "socks"
+	RunServerModeMixed       string = "mixed"
+	RunServerModeTransparent string = "transparent"
+)
+
+const (
+	defaultSocksPort       = 1080
+	defaultHttpPort        = 1081
+	defaultMixedPort       = 1082
+	defaultTransparentPort = 1090
+)
+
+const (
+	defaultProbeTarget   = "www.gstatic.com:80"
+	defaultProbeInterval = 60
 )
 
 type App struct {
@@ -33,6 +54,10 @@ type App struct {
 	// shared config
 	authConfig   AuthenticatorConfig
 	serverConfig ServerConfig
+	// destinationIPRules 是否配置了按目标 IP 地址匹配的规则（ipnet/geoip/asn/special-ranges 等）
+	destinationIPRules bool
+	// geoDatabases 按路径共享的 MaxMind 数据库，供访问规则及路由规则使用
+	geoDatabases map[string]*ruleset.GeoDatabase
 }
 
 func NewApp() *App {
@@ -84,6 +109,14 @@ func (a *App) Init(runCtx context.Context, cmdMode string) error {
 	if err := a.initRuleset(runCtx); err != nil {
 		return fmt.Errorf("inst: init ruleset: %w", err)
 	}
+	// Outbound
+	if err := a.initOutbound(runCtx); err != nil {
+		return fmt.Errorf("inst: init outbound: %w", err)
+	}
+	// Router
+	if err := a.initRouter(runCtx); err != nil {
+		return fmt.Errorf("inst: init router: %w", err)
+	}
 	// Http listener
 	if helper.ContainsAny(a.serverConfig.Mode, RunServerModeAuto, RunServerModeHttp) {
 		if err := a.initHttpListener(runCtx, a.dispatcher); err != nil {
@@ -96,6 +129,22 @@ func (a *App) Init(runCtx context.Context, cmdMode string) error {
 			return err
 		}
 	}
+	// Mixed listener
+	if helper.ContainsAny(a.serverConfig.Mode, RunServerModeAuto, RunServerModeMixed) {
+		if err := a.initMixedListener(runCtx, a.dispatcher); err != nil {
+			return err
+		}
+	}
+	// Transparent listener
+	if helper.ContainsAny(a.serverConfig.Mode, RunServerModeAuto, RunServerModeTransparent) {
+		if err := a.initTransparentListener(runCtx, a.dispatcher); err != nil {
+			return err
+		}
+	}
+	// Dns listener
+	if err := a.initDnsListener(runCtx); err != nil {
+		return err
+	}
 	if len(a.listeners) == 0 {
 		return fmt.Errorf("inst: no available listeners")
 	}
@@ -148,11 +197,20 @@ func (a *App) initHttpListener(runCtx context.Context, dispatcher proxy.Dispatch
 	}
 	lstOpts := proxy.ListenerOptions{
 		Address: convBindAddress(httpConfig.Bind),
-		Port:    convBindPort(httpConfig.Port, 1080),
+		Port:    convBindPort(httpConfig.Port, defaultHttpPort),
 		Verbose: a.serverConfig.Verbose,
 		Auth:    a.authConfig.Enabled,
 	}
-	httpOpts := listener.HttpOptions{}
+	if err := convProxyProtocol(&lstOpts, httpConfig.ProxyProtocol, httpConfig.ProxyProtocolTrusted); err != nil {
+		return fmt.Errorf("inst: http proxy protocol config. %w", err)
+	}
+	tlsOpts, err := convTlsOptions(httpConfig.Tls)
+	if err != nil {
+		return fmt.Errorf("inst: http tls config. %w", err)
+	}
+	httpOpts := listener.HttpOptions{
+		Tls: tlsOpts,
+	}
 	httpListener := listener.NewHttpListener(lstOpts, httpOpts, dispatcher)
 	a.listeners = append(a.listeners, httpListener)
 	return httpListener.Init(runCtx)
@@ -171,16 +229,135 @@ func (a *App) initSocksListener(runCtx context.Context, dispatcher proxy.Dispatc
 	}
 	lstOpts := proxy.ListenerOptions{
 		Address: convBindAddress(socksConfig.Bind),
-		Port:    convBindPort(socksConfig.Port, 1081),
+		Port:    convBindPort(socksConfig.Port, defaultSocksPort),
 		Verbose: a.serverConfig.Verbose,
 		Auth:    a.authConfig.Enabled,
 	}
-	socksOpts := listener.SocksOptions{}
+	if err := convProxyProtocol(&lstOpts, socksConfig.ProxyProtocol, socksConfig.ProxyProtocolTrusted); err != nil {
+		return fmt.Errorf("inst: socks proxy protocol config. %w", err)
+	}
+	tlsOpts, err := convTlsOptions(socksConfig.Tls)
+	if err != nil {
+		return fmt.Errorf("inst: socks tls config. %w", err)
+	}
+	socksOpts := listener.SocksOptions{
+		Tls: tlsOpts,
+	}
 	socksListener := listener.NewSocksListener(lstOpts, socksOpts, dispatcher)
 	a.listeners = append(a.listeners, socksListener)
 	return socksListener.Init(runCtx)
 }
 
+func (a *App) initMixedListener(runCtx context.Context, dispatcher proxy.Dispatcher) error {
+	assert.MustNotNil(runCtx, "context is nil")
+	assert.MustNotNil(dispatcher, "dispatcher is nil")
+	var mixedConfig MixedConfig
+	if err := unmarshalWith(runCtx, configPathServerMixed, &mixedConfig); err != nil {
+		return fmt.Errorf("inst: unmarshal mixed config. %w", err)
+	}
+	if mixedConfig.Disabled {
+		logrus.Warnf("inst: mixed server is disabled")
+		return nil
+	}
+	// auto 模式下，仅在明确配置端口时启用
+	if a.serverConfig.Mode == RunServerModeAuto && mixedConfig.Port <= 0 {
+		return nil
+	}
+	lstOpts := proxy.ListenerOptions{
+		Address: convBindAddress(mixedConfig.Bind),
+		Port:    convBindPort(mixedConfig.Port, defaultMixedPort),
+		Verbose: a.serverConfig.Verbose,
+		Auth:    a.authConfig.Enabled,
+	}
+	if err := convProxyProtocol(&lstOpts, mixedConfig.ProxyProtocol, mixedConfig.ProxyProtocolTrusted); err != nil {
+		return fmt.Errorf("inst: mixed proxy protocol config. %w", err)
+	}
+	httpTlsOpts, err := convTlsOptions(mixedConfig.HttpTls)
+	if err != nil {
+		return fmt.Errorf("inst: mixed http tls config. %w", err)
+	}
+	socksTlsOpts, err := convTlsOptions(mixedConfig.SocksTls)
+	if err != nil {
+		return fmt.Errorf("inst: mixed socks tls config. %w", err)
+	}
+	mixedOpts := listener.MixedOptions{
+		Http:  listener.HttpOptions{Tls: httpTlsOpts},
+		Socks: listener.SocksOptions{Tls: socksTlsOpts},
+	}
+	mixedListener := listener.NewMixedListener(lstOpts, mixedOpts, dispatcher)
+	a.listeners = append(a.listeners, mixedListener)
+	return mixedListener.Init(runCtx)
+}
+
+func (a *App) initTransparentListener(runCtx context.Context, dispatcher proxy.Dispatcher) error {
+	assert.MustNotNil(runCtx, "context is nil")
+	assert.MustNotNil(dispatcher, "dispatcher is nil")
+	var transparentConfig TransparentConfig
+	if err := unmarshalWith(runCtx, configPathServerTransparent, &transparentConfig); err != nil {
+		return fmt.Errorf("inst: unmarshal transparent config. %w", err)
+	}
+	if transparentConfig.Disabled {
+		logrus.Warnf("inst: transparent server is disabled")
+		return nil
+	}
+	// auto 模式下，仅在明确配置端口时启用
+	if a.serverConfig.Mode == RunServerModeAuto && transparentConfig.Port <= 0 {
+		return nil
+	}
+	if transparentConfig.Mode == "" {
+		transparentConfig.Mode = listener.TransparentModeRedirect
+	}
+	lstOpts := proxy.ListenerOptions{
+		Address: convBindAddress(transparentConfig.Bind),
+		Port:    convBindPort(transparentConfig.Port, defaultTransparentPort),
+		Verbose: a.serverConfig.Verbose,
+		Auth:    false,
+	}
+	transparentOpts := listener.TransparentOptions{
+		Mode: strings.ToLower(transparentConfig.Mode),
+	}
+	transparentListener := listener.NewTransparentListener(lstOpts, transparentOpts, dispatcher)
+	a.listeners = append(a.listeners, transparentListener)
+	return transparentListener.Init(runCtx)
+}
+
+func (a *App) initDnsListener(runCtx context.Context) error {
+	assert.MustNotNil(runCtx, "context is nil")
+	var dnsConfig DnsConfig
+	if err := unmarshalWith(runCtx, configPathServerDns, &dnsConfig); err != nil {
+		return fmt.Errorf("inst: unmarshal dns config. %w", err)
+	}
+	if dnsConfig.Disabled {
+		logrus.Warnf("inst: dns server is disabled")
+		return nil
+	}
+	// 与代理服务同时运行，仅在明确配置端口时启用
+	if dnsConfig.Port <= 0 {
+		return nil
+	}
+	if dnsConfig.Block == "" {
+		dnsConfig.Block = listener.DnsBlockNXDomain
+	}
+	// 默认仅监听本机地址，避免成为开放解析服务器
+	if dnsConfig.Bind == "" {
+		dnsConfig.Bind = "127.0.0.1"
+	} else if ip := stdnet.ParseIP(dnsConfig.Bind); ip == nil || !ip.IsLoopback() {
+		logrus.Warnf("inst: dns server binds to %s, restrict clients with a firewall", dnsConfig.Bind)
+	}
+	lstOpts := proxy.ListenerOptions{
+		Address: dnsConfig.Bind,
+		Port:    dnsConfig.Port,
+		Verbose: a.serverConfig.Verbose,
+		Auth:    false,
+	}
+	dnsOpts := listener.DnsOptions{
+		Block: strings.ToLower(dnsConfig.Block),
+	}
+	dnsListener := listener.NewDnsListener(lstOpts, dnsOpts)
+	a.listeners = append(a.listeners, dnsListener)
+	return dnsListener.Init(runCtx)
+}
+
 func (a *App) initResolver(runCtx context.Context) error {
 	var config ResolverConfig
 	if err := unmarshalWith(runCtx, configPathResolver, &config); err != nil {
@@ -192,10 +369,59 @@ func (a *App) initResolver(runCtx context.Context) error {
 	if config.CacheTTL <= 0 {
 		config.CacheTTL = 60
 	}
+	if config.MinTTL <= 0 {
+		config.MinTTL = 5
+	}
+	if config.MaxTTL <= 0 {
+		config.MaxTTL = 3600
+	}
+	if config.MinTTL > config.MaxTTL {
+		return fmt.Errorf("resolver.min_ttl(%d) is greater than max_ttl(%d)", config.MinTTL, config.MaxTTL)
+	}
+	// negative_ttl: 0 使用默认值，负数关闭否定缓存
+	if config.NegativeTTL == 0 {
+		config.NegativeTTL = 30
+	} else if config.NegativeTTL < 0 {
+		config.NegativeTTL = 0
+	}
+	var upstream *resolver.Upstream
+	if len(config.Nameservers) > 0 {
+		inst, err := convUpstream(config.Nameservers, config.Strategy, config.Timeout)
+		if err != nil {
+			return fmt.Errorf("resolver. %w", err)
+		}
+		upstream = inst
+	} else if config.ViaOutbound {
+		return fmt.Errorf("resolver.via_outbound requires resolver.nameservers")
+	}
+	ipStrategy, err := resolver.ParseIPStrategy(config.IPStrategy)
+	if err != nil {
+		return fmt.Errorf("resolver. %w", err)
+	}
+	policies := make([]resolver.Policy, 0, len(config.Policies))
+	for i, policy := range config.Policies {
+		if len(policy.Suffix) == 0 {
+			return fmt.Errorf("resolver.policies[%d]: suffix is required", i)
+		}
+		inst, err := convUpstream(policy.Nameservers, policy.Strategy, policy.Timeout)
+		if err != nil {
+			return fmt.Errorf("resolver.policies[%d]. %w", i, err)
+		}
+		policies = append(policies, resolver.NewPolicy(policy.Suffix, inst))
+	}
 	inst := feature.InitResolverWith(feature.Options{
-		CacheSize: config.CacheSize,
-		CacheTTL:  time.Duration(config.CacheTTL) * time.Second,
-		Hosts:     config.Hosts,
+		CacheSize:    config.CacheSize,
+		CacheTTL:     time.Duration(config.CacheTTL) * time.Second,
+		MinTTL:       time.Duration(config.MinTTL) * time.Second,
+		MaxTTL:       time.Duration(config.MaxTTL) * time.Second,
+		NegativeTTL:  time.Duration(config.NegativeTTL) * time.Second,
+		StaleTTL:     time.Duration(config.StaleTTL) * time.Second,
+		PrefetchHits: config.Prefetch,
+		Hosts:        config.Hosts,
+		Upstream:     upstream,
+		Policies:     policies,
+		ViaOutbound:  config.ViaOutbound,
+		IPStrategy:   ipStrategy,
 	})
 	// prepare
 	for host, ipAddr := range config.Hosts {
@@ -224,7 +450,17 @@ func (a *App) initAuthenticator(runCtx context.Context) error {
 			return fmt.Errorf("invalid user or password in authenticator.basic: %s=%s", u, p)
 		}
 	}
-	basic := authenticator.NewUsersAuthenticator(a.authConfig.Basic)
+	// Groups: 用户组 -> 用户列表，转换为 用户 -> 所属用户组
+	userGroups := make(map[string][]string)
+	for group, users := range a.authConfig.Groups {
+		for _, u := range users {
+			if _, ok := a.authConfig.Basic[u]; !ok {
+				return fmt.Errorf("invalid user in authenticator.groups: %s=%s", group, u)
+			}
+			userGroups[u] = append(userGroups[u], group)
+		}
+	}
+	basic := authenticator.NewUsersAuthenticator(a.authConfig.Basic, userGroups)
 	dispatcher.RegisterAuthenticator(proxy.AuthenticateBasic, basic)
 	// Others todo
 	return nil
@@ -245,36 +481,447 @@ func (a *App) initRuleset(runCtx context.Context) error {
 				return nil, fmt.Errorf("invalid ruleset(ipnet) address: %s", sAddr)
 			}
 		}
-		return ruleset.NewIPNet(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), nets), nil
+		if len(rule.Files) == 0 {
+			return ruleset.NewIPNet(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), nets), nil
+		}
+		return ruleset.NewListRuleset(runCtx, "ipnet", rule.Files, func(entries ruleset.ListEntries) (proxy.Ruleset, error) {
+			return ruleset.NewIPNet(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"),
+				append(slices.Clip(nets), entries.CIDRs...)), nil
+		})
+	}
+	domainBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		matcher, err := ruleset.NewDomainMatcher(rule.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ruleset(domain) address. %w", err)
+		}
+		if len(rule.Files) == 0 {
+			return ruleset.NewDomain(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), matcher), nil
+		}
+		return ruleset.NewListRuleset(runCtx, "domain", rule.Files, func(entries ruleset.ListEntries) (proxy.Ruleset, error) {
+			matcher, err := ruleset.NewDomainMatcher(append(slices.Clip(rule.Address), entries.Domains...))
+			if err != nil {
+				return nil, err
+			}
+			return ruleset.NewDomain(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), matcher), nil
+		})
+	}
+	portBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		ranges := make([]net.PortRange, 0, len(rule.Ports))
+		for _, sPort := range rule.Ports {
+			if portRange, err := net.ParsePortRange(sPort); err == nil {
+				ranges = append(ranges, portRange)
+			} else {
+				return nil, fmt.Errorf("invalid ruleset(port) ports: %s", sPort)
+			}
+		}
+		return ruleset.NewPort(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), ranges), nil
+	}
+	userBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+			return nil, fmt.Errorf("invalid ruleset(%s): users or groups is required", rule.Type)
+		}
+		return ruleset.NewUser(strings.EqualFold(rule.Access, "allow"), rule.Users, rule.Groups), nil
+	}
+	scheduleBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		location := time.Local
+		if rule.Timezone != "" {
+			if loc, err := time.LoadLocation(rule.Timezone); err == nil {
+				location = loc
+			} else {
+				return nil, fmt.Errorf("invalid ruleset(schedule) timezone: %s. %w", rule.Timezone, err)
+			}
+		}
+		weekdays := [7]bool{true, true, true, true, true, true, true}
+		if len(rule.Weekdays) > 0 {
+			if days, err := ruleset.ParseWeekdays(rule.Weekdays); err == nil {
+				weekdays = days
+			} else {
+				return nil, fmt.Errorf("invalid ruleset(schedule) weekdays. %w", err)
+			}
+		}
+		windows := make([]ruleset.TimeWindow, 0, len(rule.Times))
+		for _, sTime := range rule.Times {
+			if window, err := ruleset.ParseTimeWindow(sTime); err == nil {
+				windows = append(windows, window)
+			} else {
+				return nil, fmt.Errorf("invalid ruleset(schedule) times. %w", err)
+			}
+		}
+		return ruleset.NewSchedule(strings.EqualFold(rule.Access, "allow"), location, weekdays, windows), nil
+	}
+	openGeoDatabase := func(rule RulesetConfig) (*ruleset.GeoDatabase, error) {
+		if rule.Database == "" {
+			return nil, fmt.Errorf("invalid ruleset(%s): database is required", rule.Type)
+		}
+		return a.openGeoDatabase(runCtx, rule.Database)
+	}
+	geoipBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		db, err := openGeoDatabase(rule)
+		if err != nil {
+			return nil, err
+		}
+		return ruleset.NewGeoIP(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), db, rule.Countries), nil
+	}
+	asnBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		db, err := openGeoDatabase(rule)
+		if err != nil {
+			return nil, err
+		}
+		return ruleset.NewASN(strings.EqualFold(rule.Access, "allow"), strings.EqualFold(rule.Origin, "source"), db, rule.ASN), nil
+	}
+	var buildRuleset func(rule RulesetConfig) (proxy.Ruleset, error)
+	logicalBuilder := func(rule RulesetConfig) (proxy.Ruleset, error) {
+		conditions := make([]proxy.Ruleset, 0, len(rule.Rules))
+		for _, condConfig := range rule.Rules {
+			cond, err := buildRuleset(condConfig)
+			if err != nil {
+				return nil, err
+			}
+			if cond == nil {
+				return nil, fmt.Errorf("invalid ruleset(logical) condition type: %s", condConfig.Type)
+			}
+			conditions = append(conditions, cond)
+		}
+		inst, err := ruleset.NewLogical(strings.EqualFold(rule.Access, "allow"), rule.Op, conditions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ruleset(logical). %w", err)
+		}
+		return inst, nil
+	}
+	// 未支持的类型返回 nil
+	buildRuleset = func(rule RulesetConfig) (proxy.Ruleset, error) {
+		switch strings.ToLower(rule.Type) {
+		case "ipnet":
+			return ipnetBuilder(rule)
+		case "domain":
+			return domainBuilder(rule)
+		case "port":
+			return portBuilder(rule)
+		case "user", "group":
+			return userBuilder(rule)
+		case "schedule":
+			return scheduleBuilder(rule)
+		case "geoip":
+			return geoipBuilder(rule)
+		case "asn":
+			return asnBuilder(rule)
+		case "logical":
+			return logicalBuilder(rule)
+		default:
+			return nil, nil
+		}
 	}
 	// 最高优先级：禁止回环访问
 	rulesets := []proxy.Ruleset{
 		ruleset.NewLoopback(loadLocalAddrs(runCtx)),
 	}
-	// 第二优先级：其它规则
+	// 第二优先级：禁止访问特殊地址，不受其它规则的放行影响
 	for _, itemConfig := range config {
-		switch strings.ToLower(itemConfig.Type) {
-		case "ipnet":
-			if inst, err := ipnetBuilder(itemConfig); err != nil {
-				return err
-			} else {
-				rulesets = append(rulesets, inst)
-			}
+		if !strings.EqualFold(itemConfig.Type, "special-ranges") {
+			continue
+		}
+		categories := itemConfig.Categories
+		if len(categories) == 0 {
+			categories = ruleset.SpecialCategories()
+		}
+		if inst, err := ruleset.NewSpecialRanges(categories); err != nil {
+			return fmt.Errorf("invalid ruleset(special-ranges). %w", err)
+		} else {
+			rulesets = append(rulesets, inst)
 		}
 	}
+	// 第三优先级：其它规则
+	for _, itemConfig := range config {
+		if inst, err := buildRuleset(itemConfig); err != nil {
+			return err
+		} else if inst != nil {
+			rulesets = append(rulesets, inst)
+		}
+	}
+	a.destinationIPRules = slices.ContainsFunc(rulesets, ruleset.NeedsDestinationIP)
 	feature.InitMultiRuleset(rulesets)
 	return nil
 }
 
 func (a *App) checkServerMode(mode string) error {
 	switch strings.ToLower(mode) {
-	case RunServerModeAuto, RunServerModeHttp, RunServerModeSocks:
+	case RunServerModeAuto, RunServerModeHttp, RunServerModeSocks, RunServerModeMixed, RunServerModeTransparent:
 		return nil
 	default:
 		return fmt.Errorf("invalid server mode: %s", mode)
 	}
 }
 
+func (a *App) initOutbound(runCtx context.Context) error {
+	var configs []OutboundConfig
+	if err := unmarshalWith(runCtx, configPathOutbound, &configs); err != nil {
+		return fmt.Errorf("unmarshal outbound. %w", err)
+	}
+	dispatcher := a.dispatcher.(*feature.Dispatcher)
+	for _, config := range configs {
+		if config.Name == "" {
+			return fmt.Errorf("outbound name is required")
+		}
+		if _, exists := dispatcher.GetDialer(convOutboundName(config.Name)); exists {
+			return fmt.Errorf("outbound name is duplicated: %s", config.Name)
+		}
+		if config.Address == "" && !strings.EqualFold(config.Type, "group") {
+			return fmt.Errorf("outbound(%s) address is required", config.Name)
+		}
+		tlsConfig, err := convOutboundTls(config)
+		if err != nil {
+			return fmt.Errorf("outbound(%s) tls config. %w", config.Name, err)
+		}
+		switch strings.ToLower(config.Type) {
+		case "http":
+			dispatcher.RegisterDialer(dialer.NewHttpDialer(config.Name, dialer.HttpOptions{
+				Address:  config.Address,
+				Username: config.Username,
+				Password: config.Password,
+				Tls:      tlsConfig,
+				// 上游 HTTP 代理通常按域名过滤且本地可能无法解析，默认由上游代理解析
+				RemoteDNS: convRemoteDNS(config.RemoteDNS, true),
+			}))
+		case "socks", "socks5":
+			if tlsConfig != nil {
+				return fmt.Errorf("outbound(%s) socks not support tls", config.Name)
+			}
+			dispatcher.RegisterDialer(dialer.NewSocksDialer(config.Name, dialer.SocksOptions{
+				Address:   config.Address,
+				Username:  config.Username,
+				Password:  config.Password,
+				RemoteDNS: convRemoteDNS(config.RemoteDNS, false),
+			}))
+		case "group":
+			group, err := a.newOutboundGroup(config)
+			if err != nil {
+				return fmt.Errorf("outbound(%s) %w", config.Name, err)
+			}
+			group.Start(runCtx)
+			dispatcher.RegisterDialer(group)
+		default:
+			return fmt.Errorf("outbound(%s) type is invalid: %s", config.Name, config.Type)
+		}
+		// 由上游代理解析的域名在本地无法得知最终连接的地址
+		if outbound, ok := dispatcher.GetDialer(config.Name); ok && a.destinationIPRules {
+			if v, ok := outbound.(proxy.RemoteResolver); ok && v.RemoteResolve() {
+				logrus.Warnf("inst: outbound(%s) resolves domains remotely, destination ip rulesets (ipnet/geoip/asn/special-ranges) only check its ip destinations", config.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// newOutboundGroup 创建出站组，成员须为已定义的出站（包括先于此定义的出站组）
+func (a *App) newOutboundGroup(config OutboundConfig) (*dialer.Group, error) {
+	dispatcher := a.dispatcher.(*feature.Dispatcher)
+	strategy := strings.ToLower(config.Strategy)
+	switch strategy {
+	case "":
+		strategy = dialer.GroupFailover
+	case dialer.GroupFailover, dialer.GroupRoundRobin, dialer.GroupHashSource, dialer.GroupHashDestination, dialer.GroupLatency:
+	default:
+		return nil, fmt.Errorf("strategy is invalid: %s", config.Strategy)
+	}
+	if len(config.Members) == 0 {
+		return nil, fmt.Errorf("members is required")
+	}
+	members := make([]proxy.Dialer, 0, len(config.Members))
+	for _, name := range config.Members {
+		member, ok := dispatcher.GetDialer(convOutboundName(name))
+		if !ok {
+			return nil, fmt.Errorf("member not found: %s", name)
+		}
+		members = append(members, member)
+	}
+	if config.ProbeTarget == "" {
+		config.ProbeTarget = defaultProbeTarget
+	}
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = defaultProbeInterval
+	}
+	return dialer.NewGroup(config.Name, dialer.GroupOptions{
+		Strategy:      strategy,
+		ProbeTarget:   config.ProbeTarget,
+		ProbeInterval: time.Duration(config.ProbeInterval) * time.Second,
+	}, members), nil
+}
+
+func (a *App) initRouter(runCtx context.Context) error {
+	var config RoutingConfig
+	if err := unmarshalWith(runCtx, configPathRouting, &config); err != nil {
+		return fmt.Errorf("unmarshal routing. %w", err)
+	}
+	dispatcher := a.dispatcher.(*feature.Dispatcher)
+	checkOutbound := func(name string) (string, error) {
+		name = convOutboundName(name)
+		if _, ok := dispatcher.GetDialer(name); !ok {
+			return name, fmt.Errorf("routing outbound not found: %s", name)
+		}
+		return name, nil
+	}
+	fallback, err := checkOutbound(config.Default)
+	if err != nil {
+		return err
+	}
+	rules := make([]*router.Rule, 0, len(config.Rules))
+	for i, ruleConfig := range config.Rules {
+		rule, err := convRoutingRule(ruleConfig)
+		if err != nil {
+			return fmt.Errorf("routing.rules[%d]: %w", i, err)
+		}
+		if len(ruleConfig.GeoIP) > 0 {
+			if ruleConfig.GeoIPDatabase == "" {
+				return fmt.Errorf("routing.rules[%d]: geoip_database is required", i)
+			}
+			if rule.GeoDatabase, err = a.openGeoDatabase(runCtx, ruleConfig.GeoIPDatabase); err != nil {
+				return fmt.Errorf("routing.rules[%d]: %w", i, err)
+			}
+		}
+		if rule.Outbound, err = checkOutbound(ruleConfig.Outbound); err != nil {
+			return fmt.Errorf("routing.rules[%d]: %w", i, err)
+		}
+		if (len(rule.CIDR) > 0 || len(rule.GeoIP) > 0) && feature.UseResolver().ViaOutbound() {
+			logrus.Warnf("inst: routing.rules[%d]: resolver.via_outbound is enabled, cidr/geoip only match ip destinations", i)
+		}
+		rules = append(rules, rule)
+	}
+	feature.InitRouter(rules, fallback)
+	logrus.Infof("inst: routing: %d rules, default: %s", len(rules), fallback)
+	return nil
+}
+
+// openGeoDatabase 打开 MaxMind 数据库，相同路径的数据库仅打开一次
+func (a *App) openGeoDatabase(runCtx context.Context, path string) (*ruleset.GeoDatabase, error) {
+	if db, ok := a.geoDatabases[path]; ok {
+		return db, nil
+	}
+	db, err := ruleset.OpenGeoDatabase(runCtx, path)
+	if err != nil {
+		return nil, err
+	}
+	if a.geoDatabases == nil {
+		a.geoDatabases = make(map[string]*ruleset.GeoDatabase)
+	}
+	a.geoDatabases[path] = db
+	return db, nil
+}
+
+func convOutboundTls(config OutboundConfig) (*tls.Config, error) {
+	if !config.Tls.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         config.Tls.ServerName,
+		InsecureSkipVerify: config.Tls.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := stdnet.SplitHostPort(config.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address: %s", config.Address)
+		}
+		tlsConfig.ServerName = host
+	}
+	if config.Tls.CAFile != "" {
+		pem, err := os.ReadFile(config.Tls.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file. %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in ca file: %s", config.Tls.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func convUpstream(nameservers []string, strategy string, timeout int) (*resolver.Upstream, error) {
+	servers := make([]resolver.Nameserver, 0, len(nameservers))
+	for _, sAddr := range nameservers {
+		server, err := resolver.ParseNameserver(sAddr, nil)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	return resolver.NewUpstream(servers, strategy, time.Duration(timeout)*time.Second)
+}
+
+func convRoutingRule(config RoutingRuleConfig) (*router.Rule, error) {
+	rule := &router.Rule{
+		Users: config.User,
+	}
+	// domain_suffix/domain_keyword/domain_regex 转换为域名匹配器的规则格式
+	var patterns []string
+	for _, suffix := range config.DomainSuffix {
+		patterns = append(patterns, ruleset.DomainPrefixSuffix+strings.Trim(suffix, "."))
+	}
+	for _, keyword := range config.DomainKeyword {
+		patterns = append(patterns, ruleset.DomainPrefixKeyword+keyword)
+	}
+	for _, expr := range config.DomainRegex {
+		patterns = append(patterns, ruleset.DomainPrefixRegexp+expr)
+	}
+	if len(patterns) > 0 {
+		matcher, err := ruleset.NewDomainMatcher(patterns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain rule. %w", err)
+		}
+		rule.Domain = matcher
+	}
+	for _, code := range config.GeoIP {
+		rule.GeoIP = append(rule.GeoIP, strings.ToUpper(code))
+	}
+	for _, sPort := range config.Port {
+		portRange, err := net.ParsePortRange(sPort)
+		if err != nil {
+			return nil, err
+		}
+		rule.Ports = append(rule.Ports, portRange)
+	}
+	var err error
+	if rule.CIDR, err = convIPNets(config.CIDR); err != nil {
+		return nil, fmt.Errorf("invalid cidr. %w", err)
+	}
+	if rule.SourceCIDR, err = convIPNets(config.SourceCIDR); err != nil {
+		return nil, fmt.Errorf("invalid source_cidr. %w", err)
+	}
+	return rule, nil
+}
+
+func convIPNets(addrs []string) ([]stdnet.IPNet, error) {
+	nets := make([]stdnet.IPNet, 0, len(addrs))
+	for _, sAddr := range addrs {
+		_, ipNet, err := stdnet.ParseCIDR(sAddr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, *ipNet)
+	}
+	return nets, nil
+}
+
+// convOutboundName 内置的出站名称不区分大小写；未配置时为直连
+func convOutboundName(name string) string {
+	switch {
+	case name == "", strings.EqualFold(name, dialer.DIRECT):
+		return dialer.DIRECT
+	case strings.EqualFold(name, dialer.REJECT):
+		return dialer.REJECT
+	default:
+		return name
+	}
+}
+
+func convRemoteDNS(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
 func convBindAddress(bind string) string {
 	if bind == "" {
 		return "0.0.0.0"
@@ -288,3 +935,49 @@ func convBindPort(port int, def int) int {
 	}
 	return port
 }
+
+func convTlsOptions(config TlsConfig) (listener.TlsOptions, error) {
+	opts := listener.TlsOptions{
+		CertFile:     config.CertFile,
+		KeyFile:      config.KeyFile,
+		ClientCAFile: config.ClientCAFile,
+	}
+	switch config.MinVersion {
+	case "", "1.2":
+		opts.MinVersion = tls.VersionTLS12
+	case "1.0":
+		opts.MinVersion = tls.VersionTLS10
+	case "1.1":
+		opts.MinVersion = tls.VersionTLS11
+	case "1.3":
+		opts.MinVersion = tls.VersionTLS13
+	default:
+		return opts, fmt.Errorf("invalid tls min_version: %s", config.MinVersion)
+	}
+	return opts, nil
+}
+
+func convProxyProtocol(lstOpts *proxy.ListenerOptions, enabled bool, trusted []string) error {
+	if !enabled {
+		return nil
+	}
+	if len(trusted) == 0 {
+		return fmt.Errorf("proxy_protocol_trusted is required")
+	}
+	lstOpts.ProxyProtocol = true
+	for _, addr := range trusted {
+		if !strings.Contains(addr, "/") {
+			if ip := stdnet.ParseIP(addr); ip != nil && ip.To4() != nil {
+				addr += "/32"
+			} else {
+				addr += "/128"
+			}
+		}
+		_, ipNet, err := stdnet.ParseCIDR(addr)
+		if err != nil {
+			return fmt.Errorf("invalid proxy_protocol_trusted: %s", addr)
+		}
+		lstOpts.ProxyProtocolTrusted = append(lstOpts.ProxyProtocolTrusted, ipNet)
+	}
+	return nil
+}
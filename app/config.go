@@ -8,12 +8,17 @@ import (
 )
 
 const (
-	configPathAuthenticator = "authenticator"
-	configPathResolver      = "resolver"
-	configPathRuleset       = "ruleset"
-	configPathServer        = "server"
-	configPathServerHttp    = "server.http"
-	configPathServerSocks   = "server.socks"
+	configPathAuthenticator     = "authenticator"
+	configPathResolver          = "resolver"
+	configPathRuleset           = "ruleset"
+	configPathRouting           = "routing"
+	configPathOutbound          = "outbound"
+	configPathServer            = "server"
+	configPathServerHttp        = "server.http"
+	configPathServerSocks       = "server.socks"
+	configPathServerMixed       = "server.mixed"
+	configPathServerTransparent = "server.transparent"
+	configPathServerDns         = "server.dns"
 )
 
 ////
@@ -26,41 +31,162 @@ type ServerConfig struct {
 ////
 
 type HttpConfig struct {
+	Disabled             bool      `toml:"disabled"`
+	Bind                 string    `toml:"bind"`
+	Port                 int       `toml:"port"`
+	Tls                  TlsConfig `toml:"tls"`
+	ProxyProtocol        bool      `toml:"proxy_protocol"`
+	ProxyProtocolTrusted []string  `toml:"proxy_protocol_trusted"`
+}
+
+////
+
+type SocksConfig struct {
+	Disabled             bool      `toml:"disabled"`
+	Bind                 string    `toml:"bind"`
+	Port                 int       `toml:"port"`
+	Tls                  TlsConfig `toml:"tls"`
+	ProxyProtocol        bool      `toml:"proxy_protocol"`
+	ProxyProtocolTrusted []string  `toml:"proxy_protocol_trusted"`
+}
+
+////
+
+type TlsConfig struct {
+	CertFile     string `toml:"cert_file"`
+	KeyFile      string `toml:"key_file"`
+	MinVersion   string `toml:"min_version"`
+	ClientCAFile string `toml:"client_ca_file"`
+}
+
+////
+
+type MixedConfig struct {
+	Disabled             bool      `toml:"disabled"`
+	Bind                 string    `toml:"bind"`
+	Port                 int       `toml:"port"`
+	HttpTls              TlsConfig `toml:"http_tls"`
+	SocksTls             TlsConfig `toml:"socks_tls"`
+	ProxyProtocol        bool      `toml:"proxy_protocol"`
+	ProxyProtocolTrusted []string  `toml:"proxy_protocol_trusted"`
+}
+
+////
+
+type TransparentConfig struct {
 	Disabled bool   `toml:"disabled"`
 	Bind     string `toml:"bind"`
 	Port     int    `toml:"port"`
+	Mode     string `toml:"mode"`
 }
 
 ////
 
-type SocksConfig struct {
+type DnsConfig struct {
 	Disabled bool   `toml:"disabled"`
 	Bind     string `toml:"bind"`
 	Port     int    `toml:"port"`
+	Block    string `toml:"block"`
 }
 
 ////
 
 type ResolverConfig struct {
-	CacheSize int               `toml:"cache_size"`
-	CacheTTL  int               `toml:"cache_ttl"`
-	Hosts     map[string]string `toml:"hosts"`
+	CacheSize   int               `toml:"cache_size"`
+	CacheTTL    int               `toml:"cache_ttl"`
+	MinTTL      int               `toml:"min_ttl"`
+	MaxTTL      int               `toml:"max_ttl"`
+	NegativeTTL int               `toml:"negative_ttl"`
+	StaleTTL    int               `toml:"stale_ttl"`
+	Prefetch    int               `toml:"prefetch"`
+	Hosts       map[string]string `toml:"hosts"`
+	Nameservers []string          `toml:"nameservers"`
+	Strategy    string            `toml:"strategy"`
+	Timeout     int               `toml:"timeout"`
+	ViaOutbound bool              `toml:"via_outbound"`
+	IPStrategy  string            `toml:"ip_strategy"`
+	Policies    []ResolverPolicy  `toml:"policies"`
+}
+
+type ResolverPolicy struct {
+	Suffix      []string `toml:"suffix"`
+	Nameservers []string `toml:"nameservers"`
+	Strategy    string   `toml:"strategy"`
+	Timeout     int      `toml:"timeout"`
 }
 
 ////
 
 type AuthenticatorConfig struct {
-	Enabled bool              `toml:"enabled"`
-	Basic   map[string]string `toml:"basic"`
+	Enabled bool                `toml:"enabled"`
+	Basic   map[string]string   `toml:"basic"`
+	Groups  map[string][]string `toml:"groups"`
 }
 
 ////
 
 type RulesetConfig struct {
-	Type    string   `toml:"type"`
-	Origin  string   `toml:"origin"`
-	Access  string   `toml:"access"`
-	Address []string `toml:"address"`
+	Type       string          `toml:"type"`
+	Origin     string          `toml:"origin"`
+	Access     string          `toml:"access"`
+	Address    []string        `toml:"address"`
+	Files      []string        `toml:"files"`
+	Categories []string        `toml:"categories"`
+	Ports      []string        `toml:"ports"`
+	Database   string          `toml:"database"`
+	Countries  []string        `toml:"countries"`
+	ASN        []uint          `toml:"asn"`
+	Users      []string        `toml:"users"`
+	Groups     []string        `toml:"groups"`
+	Timezone   string          `toml:"timezone"`
+	Weekdays   []string        `toml:"weekdays"`
+	Times      []string        `toml:"times"`
+	Op         string          `toml:"op"`
+	Rules      []RulesetConfig `toml:"rules"`
+}
+
+////
+
+type OutboundConfig struct {
+	Name      string            `toml:"name"`
+	Type      string            `toml:"type"`
+	Address   string            `toml:"address"`
+	Username  string            `toml:"username"`
+	Password  string            `toml:"password"`
+	RemoteDNS *bool             `toml:"remote_dns"`
+	Tls       OutboundTlsConfig `toml:"tls"`
+	// group
+	Strategy      string   `toml:"strategy"`
+	Members       []string `toml:"members"`
+	ProbeTarget   string   `toml:"probe_target"`
+	ProbeInterval int      `toml:"probe_interval"`
+}
+
+type OutboundTlsConfig struct {
+	Enabled            bool   `toml:"enabled"`
+	ServerName         string `toml:"server_name"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+	CAFile             string `toml:"ca_file"`
+}
+
+////
+
+type RoutingConfig struct {
+	Default string              `toml:"default"`
+	Rules   []RoutingRuleConfig `toml:"rules"`
+}
+
+type RoutingRuleConfig struct {
+	Outbound      string   `toml:"outbound"`
+	DomainSuffix  []string `toml:"domain_suffix"`
+	DomainKeyword []string `toml:"domain_keyword"`
+	DomainRegex   []string `toml:"domain_regex"`
+	CIDR          []string `toml:"cidr"`
+	GeoIP         []string `toml:"geoip"`
+	GeoIPDatabase string   `toml:"geoip_database"`
+	Port          []string `toml:"port"`
+	SourceCIDR    []string `toml:"source_cidr"`
+	User          []string `toml:"user"`
 }
 
 ////
@@ -36,14 +36,26 @@ func lookupServerPorts(ctx context.Context) []Netport {
 	// http
 	var httpConfig HttpConfig
 	_ = unmarshalWith(ctx, configPathServerHttp, &httpConfig)
-	if httpConfig.Disabled == false && httpConfig.Port > 0 {
-		output = append(output, Netport{Port: httpConfig.Port, Network: net.NetworkTCP})
+	if httpConfig.Disabled == false {
+		output = append(output, Netport{Port: convBindPort(httpConfig.Port, defaultHttpPort), Network: net.NetworkTCP})
 	}
 	// socks
 	var socksConfig SocksConfig
 	_ = unmarshalWith(ctx, configPathServerSocks, &socksConfig)
-	if socksConfig.Disabled == false && socksConfig.Port > 0 {
-		output = append(output, Netport{Port: socksConfig.Port, Network: net.NetworkTCP})
+	if socksConfig.Disabled == false {
+		output = append(output, Netport{Port: convBindPort(socksConfig.Port, defaultSocksPort), Network: net.NetworkTCP})
+	}
+	// mixed
+	var mixedConfig MixedConfig
+	_ = unmarshalWith(ctx, configPathServerMixed, &mixedConfig)
+	if mixedConfig.Disabled == false {
+		output = append(output, Netport{Port: convBindPort(mixedConfig.Port, defaultMixedPort), Network: net.NetworkTCP})
+	}
+	// transparent
+	var transparentConfig TransparentConfig
+	_ = unmarshalWith(ctx, configPathServerTransparent, &transparentConfig)
+	if transparentConfig.Disabled == false {
+		output = append(output, Netport{Port: convBindPort(transparentConfig.Port, defaultTransparentPort), Network: net.NetworkTCP})
 	}
 	return output
 }
@@ -57,6 +57,16 @@ func main() {
 					Description: "Run server, mode(only): socks",
 					ExecFunc:    runAppAsSocks,
 				},
+				{
+					Name:        "mixed",
+					Description: "Run server, mode(only): mixed http and socks on one port",
+					ExecFunc:    runAppAsMixed,
+				},
+				{
+					Name:        "transparent",
+					Description: "Run server, mode(only): transparent proxy (linux)",
+					ExecFunc:    runAppAsTransparent,
+				},
 			},
 		},
 		// Config
@@ -20,6 +20,14 @@ func runAppAsSocks(runCtx context.Context, args []string) error {
 	return runAppWith(runCtx, args, app.RunServerModeSocks, false)
 }
 
+func runAppAsMixed(runCtx context.Context, args []string) error {
+	return runAppWith(runCtx, args, app.RunServerModeMixed, false)
+}
+
+func runAppAsTransparent(runCtx context.Context, args []string) error {
+	return runAppWith(runCtx, args, app.RunServerModeTransparent, false)
+}
+
 func runAppWith(runCtx context.Context, args []string, mode string, dryRun bool) error {
 	var confpath string
 	fs := flag.NewFlagSet("run-app", flag.ContinueOnError)
@@ -27,27 +27,6 @@ func (d *DirectConnection) Close() error {
 	return d.conn.Close()
 }
 
-//// Reject
-
-var (
-	_ Connection = (*RejectConnection)(nil)
-)
-
-type RejectConnection struct {
-}
-
-func NewRejectConnection() *RejectConnection {
-	return &RejectConnection{}
-}
-
-func (r *RejectConnection) Conn() net.Conn {
-	return nil
-}
-
-func (r *RejectConnection) Close() error {
-	return nil
-}
-
 ////
 
 type nopReadWriter struct {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/knadh/koanf/v2"
 	"github.com/sirupsen/logrus"
+	stdnet "net"
 )
 
 type contextKey struct {
@@ -11,9 +12,11 @@ type contextKey struct {
 }
 
 var (
-	CtxKeyID       = contextKey{key: "ctx-key-id"}
-	CtxKeySource   = contextKey{key: "ctx-key-source"}
-	CtxKeyConfiger = contextKey{key: "ctx-key-configer"}
+	CtxKeyID        = contextKey{key: "ctx-key-id"}
+	CtxKeySource    = contextKey{key: "ctx-key-source"}
+	CtxKeyConfiger  = contextKey{key: "ctx-key-configer"}
+	CtxKeyPrincipal = contextKey{key: "ctx-key-principal"}
+	CtxKeyDialIPs   = contextKey{key: "ctx-key-dial-ips"}
 )
 
 func Logger(ctx context.Context) *logrus.Entry {
@@ -28,3 +31,29 @@ func Configer(ctx context.Context) *koanf.Koanf {
 	}
 	panic("Configer is not in context.")
 }
+
+// ContextWithPrincipal 记录通过身份认证的用户身份
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, CtxKeyPrincipal, principal)
+}
+
+// ContextPrincipal 返回通过身份认证的用户身份；未认证时返回空的 Principal
+func ContextPrincipal(ctx context.Context) Principal {
+	if v, ok := ctx.Value(CtxKeyPrincipal).(Principal); ok {
+		return v
+	}
+	return Principal{}
+}
+
+// ContextWithDialIPs 记录目标域名解析得到的全部可用地址，Dialer 可在连接失败时尝试其它地址
+func ContextWithDialIPs(ctx context.Context, ips []stdnet.IP) context.Context {
+	return context.WithValue(ctx, CtxKeyDialIPs, ips)
+}
+
+// DialIPs 返回目标域名解析得到的全部可用地址；目标地址为 IP 地址时返回 nil
+func DialIPs(ctx context.Context) []stdnet.IP {
+	if v, ok := ctx.Value(CtxKeyDialIPs).([]stdnet.IP); ok {
+		return v
+	}
+	return nil
+}
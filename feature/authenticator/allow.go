@@ -17,8 +17,8 @@ func NewAllowAuthenticator() *AllowAuthenticator {
 	return &AllowAuthenticator{}
 }
 
-func (a *AllowAuthenticator) Authenticate(ctx context.Context, authentication proxy.Authentication) error {
-	return nil
+func (a *AllowAuthenticator) Authenticate(ctx context.Context, authentication proxy.Authentication) (proxy.Principal, error) {
+	return proxy.Principal{}, nil
 }
 
 ////
@@ -30,6 +30,6 @@ func NewDenyAuthenticator() *DenyAuthenticator {
 	return &DenyAuthenticator{}
 }
 
-func (a *DenyAuthenticator) Authenticate(ctx context.Context, authentication proxy.Authentication) error {
-	return errors.New("authenticate deny for all")
+func (a *DenyAuthenticator) Authenticate(ctx context.Context, authentication proxy.Authentication) (proxy.Principal, error) {
+	return proxy.Principal{}, errors.New("authenticate deny for all")
 }
@@ -17,28 +17,30 @@ var (
 )
 
 type BasicAuthenticator struct {
-	users map[string]string
+	users  map[string]string
+	groups map[string][]string
 }
 
-func NewUsersAuthenticator(users map[string]string) *BasicAuthenticator {
-	return &BasicAuthenticator{users: users}
+// NewUsersAuthenticator 创建 Basic 认证；groups 为用户名到所属用户组的映射
+func NewUsersAuthenticator(users map[string]string, groups map[string][]string) *BasicAuthenticator {
+	return &BasicAuthenticator{users: users, groups: groups}
 }
 
-func (u *BasicAuthenticator) Authenticate(ctx context.Context, auth proxy.Authentication) error {
+func (u *BasicAuthenticator) Authenticate(ctx context.Context, auth proxy.Authentication) (proxy.Principal, error) {
 	username, password, ok := strings.Cut(auth.Authentication, ":")
 	if !ok {
-		return ErrUPInvalidUsernameOrPassword
+		return proxy.Principal{}, ErrUPInvalidUsernameOrPassword
 	}
 	// check username and password
 	if username == "" {
-		return ErrUPInvalidUsernameOrPassword
+		return proxy.Principal{}, ErrUPInvalidUsernameOrPassword
 	}
 	if password == "" {
-		return ErrUPInvalidUsernameOrPassword
+		return proxy.Principal{}, ErrUPInvalidUsernameOrPassword
 	}
 	if u.users[username] != password {
-		return ErrUPAuthenticateFailed
+		return proxy.Principal{}, ErrUPAuthenticateFailed
 	} else {
-		return nil // success
+		return proxy.Principal{Name: username, Groups: u.groups[username]}, nil // success
 	}
 }
@@ -0,0 +1,147 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/fluxproxy/fluxproxy/statute/socks"
+	stdnet "net"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ proxy.Connector = (*DatagramConnector)(nil)
+)
+
+const (
+	datagramIdleTimeout = time.Second * 60
+	datagramBufferSize  = 64 * 1024
+	datagramQueueSize   = 64
+)
+
+// DatagramConnector 承载 UDP ASSOCIATE 中单个目标地址的数据报转发
+type DatagramConnector struct {
+	src        net.Address
+	dest       net.Address
+	destSpec   socks.AddrSpec
+	relay      *stdnet.UDPConn
+	client     *stdnet.UDPAddr
+	packets    chan []byte
+	active     atomic.Int64
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+}
+
+func NewDatagramConnector(
+	ctx context.Context,
+	relay *stdnet.UDPConn,
+	client *stdnet.UDPAddr,
+	destSpec socks.AddrSpec,
+	dest net.Address,
+	src net.Address,
+) *DatagramConnector {
+	ctx, cancel := context.WithCancel(ctx)
+	d := &DatagramConnector{
+		src:        src,
+		dest:       dest,
+		destSpec:   destSpec,
+		relay:      relay,
+		client:     client,
+		packets:    make(chan []byte, datagramQueueSize),
+		ctx:        ctx,
+		cancelFunc: cancel,
+	}
+	d.active.Store(time.Now().UnixNano())
+	return d
+}
+
+// Deliver 投递来自客户端的数据报；队列已满时丢弃该数据报
+func (d *DatagramConnector) Deliver(data []byte) bool {
+	select {
+	case <-d.ctx.Done():
+		return false
+	case d.packets <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *DatagramConnector) Connect(connection proxy.Connection) error {
+	defer d.cancelFunc()
+	remote := connection.Conn()
+	ioErrors := make(chan error, 2)
+	// src-to-dest
+	go func() {
+		for {
+			select {
+			case <-d.ctx.Done():
+				ioErrors <- d.ctx.Err()
+				return
+			case data := <-d.packets:
+				d.active.Store(time.Now().UnixNano())
+				if _, err := remote.Write(data); err != nil {
+					ioErrors <- err
+					return
+				}
+			}
+		}
+	}()
+	// dest-to-src
+	go func() {
+		buf := make([]byte, datagramBufferSize)
+		for {
+			_ = remote.SetReadDeadline(time.Now().Add(datagramIdleTimeout))
+			n, err := remote.Read(buf)
+			if err != nil {
+				var netErr stdnet.Error
+				if errors.As(err, &netErr) && netErr.Timeout() && !d.isIdle() {
+					continue
+				}
+				ioErrors <- err
+				return
+			}
+			d.active.Store(time.Now().UnixNano())
+			packet := socks.Datagram{DstAddr: d.destSpec, Data: buf[:n]}
+			if _, err := d.relay.WriteToUDP(packet.Bytes(), d.client); err != nil {
+				ioErrors <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-ioErrors:
+		return err
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	}
+}
+
+func (d *DatagramConnector) isIdle() bool {
+	return time.Since(time.Unix(0, d.active.Load())) >= datagramIdleTimeout
+}
+
+func (d *DatagramConnector) Close() error {
+	d.cancelFunc()
+	return nil
+}
+
+func (d *DatagramConnector) HookFunc(key any) (proxy.HookFunc, bool) {
+	v, ok := d.ctx.Value(key).(proxy.HookFunc)
+	return v, ok
+}
+
+func (d *DatagramConnector) Context() context.Context {
+	return d.ctx
+}
+
+func (d *DatagramConnector) Source() net.Address {
+	return d.src
+}
+
+func (d *DatagramConnector) Destination() net.Address {
+	return d.dest
+}
@@ -33,9 +33,25 @@ func (d *TcpDirectDialer) Dial(connCtx context.Context, remoteAddr net.Address)
 		Timeout:   time.Second * 5,
 		KeepAlive: time.Duration(0),
 	}
-	conn, err := dialer.DialContext(connCtx, "tcp", remoteAddr.Addrport())
+	// UDP ASSOCIATE 的数据报转发同样由直连方式建立
+	if remoteAddr.Network == net.NetworkUDP {
+		conn, err := dialer.DialContext(connCtx, "udp", remoteAddr.Addrport())
+		if err != nil {
+			return nil, fmt.Errorf("udp dail. %w. %w", err, ErrTargetFailure)
+		}
+		return proxy.NewDirectConnection(conn), nil
+	}
+	var conn stdnet.Conn
+	var err error
+	// 目标域名解析得到多个地址时，以 Happy Eyeballs 方式连接，单个地址不可用时尝试其它地址
+	if ips := proxy.DialIPs(connCtx); len(ips) > 1 && remoteAddr.IsIP() && ips[0].Equal(remoteAddr.IP) {
+		conn, err = dialHappyEyeballs(connCtx, dialer, ips, remoteAddr.Port)
+	} else {
+		conn, err = dialer.DialContext(connCtx, "tcp", remoteAddr.Addrport())
+	}
+	// 直连时无法连接即为目标地址的错误
 	if err != nil {
-		return nil, fmt.Errorf("tcp dail. %w", err)
+		return nil, fmt.Errorf("tcp dail. %w. %w", err, ErrTargetFailure)
 	}
 	_ = (conn.(*stdnet.TCPConn)).SetKeepAlive(true)
 	return proxy.NewDirectConnection(conn), nil
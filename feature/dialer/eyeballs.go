@@ -0,0 +1,104 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	stdnet "net"
+	"strconv"
+	"time"
+)
+
+// connectionAttemptDelay RFC 8305 建议的连接尝试间隔
+const connectionAttemptDelay = 250 * time.Millisecond
+
+// dialHappyEyeballs 按 RFC 8305 交替地址族依次发起 TCP 连接：每隔 connectionAttemptDelay 发起下一个连接，
+// 连接失败时立即尝试下一个地址，返回最先建立的连接并关闭其它连接
+func dialHappyEyeballs(ctx context.Context, dialer *stdnet.Dialer, ips []stdnet.IP, port int) (stdnet.Conn, error) {
+	type result struct {
+		conn stdnet.Conn
+		err  error
+	}
+	ips = interleaveFamilies(ips)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(ips))
+	next, pending := 0, 0
+	attempt := func() {
+		address := stdnet.JoinHostPort(ips[next].String(), strconv.Itoa(port))
+		next++
+		pending++
+		go func() {
+			conn, err := dialer.DialContext(ctx, "tcp", address)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+	attempt()
+	timer := time.NewTimer(connectionAttemptDelay)
+	defer timer.Stop()
+	var errs []error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// 关闭其它晚到的连接
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			errs = append(errs, r.err)
+			if next < len(ips) {
+				attempt()
+				resetTimer(timer, connectionAttemptDelay)
+			}
+		case <-timer.C:
+			if next < len(ips) {
+				attempt()
+				timer.Reset(connectionAttemptDelay)
+			}
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// interleaveFamilies 以首个地址的地址族开始，交替排列 IPv4 与 IPv6 地址，同一地址族内保持原有顺序
+func interleaveFamilies(ips []stdnet.IP) []stdnet.IP {
+	if len(ips) == 0 {
+		return ips
+	}
+	first := ips[0].To4() != nil
+	primary := make([]stdnet.IP, 0, len(ips))
+	secondary := make([]stdnet.IP, 0, len(ips))
+	for _, ip := range ips {
+		if (ip.To4() != nil) == first {
+			primary = append(primary, ip)
+		} else {
+			secondary = append(secondary, ip)
+		}
+	}
+	out := make([]stdnet.IP, 0, len(ips))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			out = append(out, primary[i])
+		}
+		if i < len(secondary) {
+			out = append(out, secondary[i])
+		}
+	}
+	return out
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
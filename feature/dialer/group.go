@@ -0,0 +1,242 @@
+package dialer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/sirupsen/logrus"
+	"hash/fnv"
+	stdnet "net"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ proxy.Dialer         = (*Group)(nil)
+	_ proxy.RemoteResolver = (*Group)(nil)
+)
+
+// group strategy
+const (
+	GroupFailover        = "failover"
+	GroupRoundRobin      = "round-robin"
+	GroupHashSource      = "hash-source"
+	GroupHashDestination = "hash-destination"
+	GroupLatency         = "latency"
+)
+
+const (
+	groupProbeTimeout = time.Second * 5
+)
+
+var (
+	ErrGroupNoMembers = errors.New("group: no members")
+	// ErrTargetFailure 上游代理可用，但无法连接目标地址
+	ErrTargetFailure = errors.New("target failure")
+	// ErrNotSupportNetwork 出站不支持目标地址的网络类型
+	ErrNotSupportNetwork = errors.New("not support network")
+)
+
+type GroupOptions struct {
+	Strategy string
+	// ProbeTarget 健康检查的目标地址，host:port
+	ProbeTarget   string
+	ProbeInterval time.Duration
+}
+
+// Group 由多个 Dialer 组成的出站组，按策略选择成员建立连接；
+// 成员建立连接失败时，依次尝试其它成员，并由后台健康检查恢复其可用状态。
+type Group struct {
+	name    string
+	opts    GroupOptions
+	members []*groupMember
+	next    atomic.Uint32
+}
+
+type groupMember struct {
+	dialer  proxy.Dialer
+	alive   atomic.Bool
+	latency atomic.Int64
+}
+
+func NewGroup(name string, opts GroupOptions, dialers []proxy.Dialer) *Group {
+	members := make([]*groupMember, 0, len(dialers))
+	for _, d := range dialers {
+		m := &groupMember{dialer: d}
+		m.alive.Store(true)
+		members = append(members, m)
+	}
+	return &Group{
+		name:    name,
+		opts:    opts,
+		members: members,
+	}
+}
+
+func (g *Group) Name() string {
+	return g.name
+}
+
+// RemoteResolve 仅当全部成员均由远端解析域名时返回 true
+func (g *Group) RemoteResolve() bool {
+	for _, m := range g.members {
+		if v, ok := m.dialer.(proxy.RemoteResolver); !ok || !v.RemoteResolve() {
+			return false
+		}
+	}
+	return len(g.members) > 0
+}
+
+func (g *Group) Dial(connCtx context.Context, remoteAddr net.Address) (proxy.Connection, error) {
+	if len(g.members) == 0 {
+		return nil, ErrGroupNoMembers
+	}
+	var errs []error
+	for _, m := range g.order(connCtx, remoteAddr) {
+		conn, err := m.dialer.Dial(connCtx, remoteAddr)
+		if err == nil {
+			return conn, nil
+		}
+		if errors.Is(connCtx.Err(), context.Canceled) {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.dialer.Name(), err))
+		// 目标自身的错误不代表成员不可用
+		if !isTargetError(err) {
+			g.markAlive(m, false)
+		}
+	}
+	return nil, fmt.Errorf("group(%s): %w", g.name, errors.Join(errs...))
+}
+
+// Start 启动后台健康检查，随 runCtx 结束而停止
+func (g *Group) Start(runCtx context.Context) {
+	if g.opts.ProbeInterval <= 0 || g.opts.ProbeTarget == "" {
+		return
+	}
+	target, err := net.ParseAddress(net.NetworkTCP, g.opts.ProbeTarget)
+	if err != nil {
+		logrus.Errorf("group(%s): invalid probe target: %s", g.name, g.opts.ProbeTarget)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(g.opts.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			g.probe(runCtx, target)
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (g *Group) probe(runCtx context.Context, target net.Address) {
+	done := make(chan struct{}, len(g.members))
+	for _, m := range g.members {
+		go func(m *groupMember) {
+			defer func() { done <- struct{}{} }()
+			probeCtx, cancel := context.WithTimeout(runCtx, groupProbeTimeout)
+			defer cancel()
+			start := time.Now()
+			conn, err := m.dialer.Dial(probeCtx, target)
+			if err != nil {
+				g.markAlive(m, false)
+				return
+			}
+			helper.Close(conn)
+			m.latency.Store(int64(time.Since(start)))
+			g.markAlive(m, true)
+		}(m)
+	}
+	for range g.members {
+		<-done
+	}
+}
+
+func (g *Group) markAlive(m *groupMember, alive bool) {
+	if m.alive.Swap(alive) != alive {
+		if alive {
+			logrus.Infof("group(%s): member up: %s", g.name, m.dialer.Name())
+		} else {
+			logrus.Warnf("group(%s): member down: %s", g.name, m.dialer.Name())
+		}
+	}
+}
+
+// order 按策略返回成员的尝试顺序：可用成员在前，不可用成员在后
+func (g *Group) order(connCtx context.Context, remoteAddr net.Address) []*groupMember {
+	alive := make([]*groupMember, 0, len(g.members))
+	down := make([]*groupMember, 0)
+	for _, m := range g.members {
+		if m.alive.Load() {
+			alive = append(alive, m)
+		} else {
+			down = append(down, m)
+		}
+	}
+	switch g.opts.Strategy {
+	case GroupRoundRobin:
+		if n := len(alive); n > 1 {
+			offset := int(g.next.Add(1)-1) % n
+			alive = append(alive[offset:], alive[:offset]...)
+		}
+	case GroupHashSource:
+		sortByRendezvous(alive, sourceHost(connCtx))
+	case GroupHashDestination:
+		sortByRendezvous(alive, remoteAddr.Addr())
+	case GroupLatency:
+		// 尚未探测的成员（latency 为 0）排在已探测的成员之后
+		slices.SortStableFunc(alive, func(a, b *groupMember) int {
+			la, lb := a.latency.Load(), b.latency.Load()
+			if (la == 0) != (lb == 0) {
+				if la == 0 {
+					return 1
+				}
+				return -1
+			}
+			return cmp.Compare(la, lb)
+		})
+	default: // GroupFailover
+	}
+	return append(alive, down...)
+}
+
+// sortByRendezvous 按一致性哈希（Rendezvous hashing）排序，成员变化时仅影响少量 key 的分配
+func sortByRendezvous(members []*groupMember, key string) {
+	score := func(m *groupMember) uint64 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte(m.dialer.Name()))
+		return h.Sum64()
+	}
+	slices.SortStableFunc(members, func(a, b *groupMember) int {
+		sa, sb := score(a), score(b)
+		if sa > sb {
+			return -1
+		} else if sa < sb {
+			return 1
+		}
+		return 0
+	})
+}
+
+func sourceHost(connCtx context.Context) string {
+	source, _ := connCtx.Value(proxy.CtxKeySource).(string)
+	if host, _, err := stdnet.SplitHostPort(source); err == nil {
+		return host
+	}
+	return source
+}
+
+// isTargetError 返回是否为目标地址自身的错误；仅成员自身的连接、握手失败才标记成员不可用
+func isTargetError(err error) bool {
+	return errors.Is(err, ErrRejected) || errors.Is(err, ErrTargetFailure) || errors.Is(err, ErrNotSupportNetwork)
+}
@@ -0,0 +1,154 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDialer struct {
+	name string
+	err  error
+}
+
+func (d *fakeDialer) Name() string {
+	return d.name
+}
+
+func (d *fakeDialer) Dial(context.Context, net.Address) (proxy.Connection, error) {
+	return nil, d.err
+}
+
+func TestGroupMarkDown(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantAlive bool
+	}{
+		{"rejected", ErrRejected, true},
+		{"target refused", fmt.Errorf("tcp dail. connection refused. %w", ErrTargetFailure), true},
+		{"unsupported network", fmt.Errorf("http upstream: %w: udp", ErrNotSupportNetwork), true},
+		{"upstream dial", errors.New("http upstream: tcp dail. connection refused"), false},
+		{"upstream auth", errors.New("socks upstream: authenticate failed"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGroup("g", GroupOptions{Strategy: GroupFailover}, []proxy.Dialer{&fakeDialer{name: "m", err: tt.err}})
+			_, err := g.Dial(context.Background(), net.ParseDomainAddr(net.NetworkTCP, "example.com"))
+			require.Error(t, err)
+			assert.Equal(t, tt.wantAlive, g.members[0].alive.Load())
+		})
+	}
+}
+
+func newTestGroup(strategy string, names ...string) *Group {
+	dialers := make([]proxy.Dialer, 0, len(names))
+	for _, name := range names {
+		dialers = append(dialers, &fakeDialer{name: name})
+	}
+	return NewGroup("g", GroupOptions{Strategy: strategy}, dialers)
+}
+
+func memberNames(members []*groupMember) []string {
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		names = append(names, m.dialer.Name())
+	}
+	return names
+}
+
+func sourceContext(source string) context.Context {
+	return context.WithValue(context.Background(), proxy.CtxKeySource, source)
+}
+
+func TestGroupOrderRoundRobin(t *testing.T) {
+	g := newTestGroup(GroupRoundRobin, "a", "b", "c")
+	dest := net.ParseDomainAddr(net.NetworkTCP, "example.com")
+	for _, want := range [][]string{{"a", "b", "c"}, {"b", "c", "a"}, {"c", "a", "b"}, {"a", "b", "c"}} {
+		assert.Equal(t, want, memberNames(g.order(context.Background(), dest)))
+	}
+	// 不可用的成员排在最后，不参与轮询
+	g.members[1].alive.Store(false)
+	for _, want := range [][]string{{"a", "c", "b"}, {"c", "a", "b"}} {
+		assert.Equal(t, want, memberNames(g.order(context.Background(), dest)))
+	}
+}
+
+func TestGroupOrderHashSource(t *testing.T) {
+	g := newTestGroup(GroupHashSource, "a", "b", "c", "d")
+	dest := net.ParseDomainAddr(net.NetworkTCP, "example.com")
+	// 同一客户端 IP 的不同端口使用相同的顺序
+	order := memberNames(g.order(sourceContext("10.0.0.1:1234"), dest))
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, order)
+	assert.Equal(t, order, memberNames(g.order(sourceContext("10.0.0.1:5678"), dest)))
+	assert.Equal(t, order, memberNames(g.order(sourceContext("10.0.0.1:5678"), net.ParseDomainAddr(net.NetworkTCP, "other.com"))))
+	// 首选成员不可用时，仅其分配的客户端改用下一个成员，其余成员的相对顺序不变
+	for _, m := range g.members {
+		if m.dialer.Name() == order[0] {
+			m.alive.Store(false)
+		}
+	}
+	assert.Equal(t, append(order[1:], order[0]), memberNames(g.order(sourceContext("10.0.0.1:1234"), dest)))
+	// 不同客户端分散到不同成员
+	first := make(map[string]struct{})
+	for i := 0; i < 64; i++ {
+		source := fmt.Sprintf("10.0.1.%d:80", i)
+		first[memberNames(g.order(sourceContext(source), dest))[0]] = struct{}{}
+	}
+	assert.Greater(t, len(first), 1)
+}
+
+func TestGroupOrderHashDestination(t *testing.T) {
+	g := newTestGroup(GroupHashDestination, "a", "b", "c", "d")
+	dest := net.ParseDomainAddr(net.NetworkTCP, "example.com")
+	order := memberNames(g.order(sourceContext("10.0.0.1:1234"), dest))
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, order)
+	assert.Equal(t, order, memberNames(g.order(sourceContext("10.0.0.2:1234"), dest)))
+	// 非首选成员不可用时，不影响已分配的目标
+	for _, m := range g.members {
+		if m.dialer.Name() == order[2] {
+			m.alive.Store(false)
+		}
+	}
+	assert.Equal(t, []string{order[0], order[1], order[3], order[2]}, memberNames(g.order(context.Background(), dest)))
+	first := make(map[string]struct{})
+	for i := 0; i < 64; i++ {
+		target := net.ParseDomainAddr(net.NetworkTCP, fmt.Sprintf("host%d.example.com", i))
+		first[memberNames(g.order(context.Background(), target))[0]] = struct{}{}
+	}
+	assert.Greater(t, len(first), 1)
+}
+
+func TestGroupOrderLatency(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []time.Duration // 0 为尚未探测
+		down      []bool
+		want      []string
+	}{
+		{"measured", []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond}, nil, []string{"b", "c", "a"}},
+		{"unprobed last", []time.Duration{0, 10 * time.Millisecond, 0}, nil, []string{"b", "a", "c"}},
+		{"none probed", []time.Duration{0, 0, 0}, nil, []string{"a", "b", "c"}},
+		{"down last", []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 0}, []bool{false, true, false}, []string{"a", "c", "b"}},
+		{"large latency", []time.Duration{time.Hour, 3 * time.Second, 5 * time.Second}, nil, []string{"b", "c", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGroup(GroupLatency, "a", "b", "c")
+			for i, m := range g.members {
+				m.latency.Store(int64(tt.latencies[i]))
+				if tt.down != nil {
+					m.alive.Store(!tt.down[i])
+				}
+			}
+			assert.Equal(t, tt.want, memberNames(g.order(context.Background(), net.ParseDomainAddr(net.NetworkTCP, "example.com"))))
+		})
+	}
+}
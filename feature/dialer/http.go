@@ -0,0 +1,142 @@
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/net"
+	stdnet "net"
+	"net/http"
+	"time"
+)
+
+var (
+	_ proxy.Dialer         = (*HttpDialer)(nil)
+	_ proxy.RemoteResolver = (*HttpDialer)(nil)
+)
+
+const (
+	upstreamDialTimeout      = time.Second * 5
+	upstreamHandshakeTimeout = time.Second * 10
+)
+
+type HttpOptions struct {
+	// Address 上游代理地址，host:port
+	Address  string
+	Username string
+	Password string
+	// Tls 不为 nil 时，使用 TLS 连接上游代理
+	Tls *tls.Config
+	// RemoteDNS 以 CONNECT host:port 将域名原样发送给上游代理解析
+	RemoteDNS bool
+}
+
+// HttpDialer 通过上游 HTTP 代理的 CONNECT 方法建立隧道连接
+type HttpDialer struct {
+	name string
+	opts HttpOptions
+}
+
+func NewHttpDialer(name string, opts HttpOptions) *HttpDialer {
+	return &HttpDialer{
+		name: name,
+		opts: opts,
+	}
+}
+
+func (d *HttpDialer) Name() string {
+	return d.name
+}
+
+func (d *HttpDialer) RemoteResolve() bool {
+	return d.opts.RemoteDNS
+}
+
+func (d *HttpDialer) Dial(connCtx context.Context, remoteAddr net.Address) (proxy.Connection, error) {
+	if remoteAddr.Network != net.NetworkTCP {
+		return nil, fmt.Errorf("http upstream: %w: %s", ErrNotSupportNetwork, remoteAddr.Network)
+	}
+	conn, err := dialUpstream(connCtx, d.opts.Address, d.opts.Tls)
+	if err != nil {
+		return nil, fmt.Errorf("http upstream: %w", err)
+	}
+	tunnel, err := d.connect(conn, remoteAddr.Addrport())
+	if err != nil {
+		helper.Close(conn)
+		return nil, fmt.Errorf("http upstream: %s: %w", d.opts.Address, err)
+	}
+	return proxy.NewDirectConnection(tunnel), nil
+}
+
+func (d *HttpDialer) connect(conn stdnet.Conn, target string) (stdnet.Conn, error) {
+	_ = conn.SetDeadline(time.Now().Add(upstreamHandshakeTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+	request := "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n"
+	if d.opts.Username != "" {
+		token := base64.StdEncoding.EncodeToString([]byte(d.opts.Username + ":" + d.opts.Password))
+		request += "Proxy-Authorization: Basic " + token + "\r\n"
+	}
+	request += "\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, fmt.Errorf("send connect. %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, fmt.Errorf("read connect response. %w", err)
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// continue
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return nil, fmt.Errorf("connect %s: %s", target, resp.Status)
+	default:
+		// 上游代理已响应，其它状态均为目标地址的错误，例如 502/504 或被上游规则拒绝
+		return nil, fmt.Errorf("connect %s: %s. %w", target, resp.Status, ErrTargetFailure)
+	}
+	// 上游代理可能在响应之后立即转发了目标服务器的数据
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+////
+
+// dialUpstream 建立到上游代理的 TCP 连接，tlsConfig 不为 nil 时完成 TLS 握手
+func dialUpstream(connCtx context.Context, address string, tlsConfig *tls.Config) (stdnet.Conn, error) {
+	dialer := &stdnet.Dialer{
+		Timeout: upstreamDialTimeout,
+	}
+	conn, err := dialer.DialContext(connCtx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("tcp dail. %w", err)
+	}
+	if tlsConfig == nil {
+		return conn, nil
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	hsCtx, hsCancel := context.WithTimeout(connCtx, upstreamHandshakeTimeout)
+	defer hsCancel()
+	if err := tlsConn.HandshakeContext(hsCtx); err != nil {
+		helper.Close(conn)
+		return nil, fmt.Errorf("tls handshake. %w", err)
+	}
+	return tlsConn, nil
+}
+
+// bufferedConn 优先读取握手阶段已缓冲的数据
+type bufferedConn struct {
+	stdnet.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,92 @@
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	proxynet "github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveConnect 启动模拟的上游 HTTP 代理，返回收到的 CONNECT 请求
+func serveConnect(t *testing.T, status string) (string, <-chan *http.Request) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	requests := make(chan *http.Request, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- req
+		_, _ = conn.Write([]byte("HTTP/1.1 " + status + "\r\n\r\n"))
+	}()
+	return listener.Addr().String(), requests
+}
+
+func TestHttpDialerConnectRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		username string
+		wantHost string
+		wantAuth string
+	}{
+		{"domain", "example.com:443", "", "example.com:443", ""},
+		{"ipv4", "10.0.0.1:80", "", "10.0.0.1:80", ""},
+		{"ipv6", "[2001:db8::1]:443", "", "[2001:db8::1]:443", ""},
+		{"basic auth", "example.com:443", "user", "example.com:443", "Basic dXNlcjpwYXNz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, requests := serveConnect(t, "200 Connection established")
+			d := NewHttpDialer("up", HttpOptions{Address: address, Username: tt.username, Password: "pass"})
+			target, err := proxynet.ParseAddress(proxynet.NetworkTCP, tt.target)
+			require.NoError(t, err)
+			conn, err := d.Dial(context.Background(), target)
+			require.NoError(t, err)
+			defer conn.Close()
+			req := <-requests
+			assert.Equal(t, http.MethodConnect, req.Method)
+			assert.Equal(t, tt.wantHost, req.RequestURI)
+			assert.Equal(t, tt.wantHost, req.Host)
+			assert.Equal(t, tt.wantAuth, req.Header.Get("Proxy-Authorization"))
+		})
+	}
+}
+
+func TestHttpDialerConnectFailure(t *testing.T) {
+	tests := []struct {
+		status     string
+		wantTarget bool
+	}{
+		{"502 Bad Gateway", true},
+		{"504 Gateway Timeout", true},
+		{"403 Forbidden", true},
+		{"407 Proxy Authentication Required", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			address, _ := serveConnect(t, tt.status)
+			d := NewHttpDialer("up", HttpOptions{Address: address})
+			_, err := d.Dial(context.Background(), proxynet.ParseDomainAddr(proxynet.NetworkTCP, "example.com"))
+			require.Error(t, err)
+			assert.Equal(t, tt.wantTarget, isTargetError(err))
+		})
+	}
+}
+
+func TestHttpDialerRemoteResolve(t *testing.T) {
+	assert.True(t, NewHttpDialer("up", HttpOptions{RemoteDNS: true}).RemoteResolve())
+	assert.False(t, NewHttpDialer("up", HttpOptions{}).RemoteResolve())
+}
@@ -2,6 +2,7 @@ package dialer
 
 import (
 	"context"
+	"errors"
 	"github.com/fluxproxy/fluxproxy"
 	"github.com/fluxproxy/fluxproxy/net"
 )
@@ -14,6 +15,10 @@ const (
 	REJECT = "REJECT"
 )
 
+var (
+	ErrRejected = errors.New("rejected")
+)
+
 type Reject struct {
 }
 
@@ -26,5 +31,5 @@ func (r *Reject) Name() string {
 }
 
 func (r *Reject) Dial(srcConnCtx context.Context, address net.Address) (proxy.Connection, error) {
-	return proxy.NewRejectConnection(), nil
+	return nil, ErrRejected
 }
@@ -0,0 +1,167 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/fluxproxy/fluxproxy/statute/socks"
+	stdnet "net"
+	"time"
+)
+
+var (
+	_ proxy.Dialer         = (*SocksDialer)(nil)
+	_ proxy.RemoteResolver = (*SocksDialer)(nil)
+)
+
+type SocksOptions struct {
+	// Address 上游代理地址，host:port
+	Address  string
+	Username string
+	Password string
+	// RemoteDNS 将域名原样发送给上游代理，由上游代理解析
+	RemoteDNS bool
+}
+
+// SocksDialer 通过上游 SOCKS5 代理的 CONNECT 命令建立连接
+type SocksDialer struct {
+	name string
+	opts SocksOptions
+}
+
+func NewSocksDialer(name string, opts SocksOptions) *SocksDialer {
+	return &SocksDialer{
+		name: name,
+		opts: opts,
+	}
+}
+
+func (d *SocksDialer) Name() string {
+	return d.name
+}
+
+func (d *SocksDialer) RemoteResolve() bool {
+	return d.opts.RemoteDNS
+}
+
+func (d *SocksDialer) Dial(connCtx context.Context, remoteAddr net.Address) (proxy.Connection, error) {
+	if remoteAddr.Network != net.NetworkTCP {
+		return nil, fmt.Errorf("socks upstream: %w: %s", ErrNotSupportNetwork, remoteAddr.Network)
+	}
+	conn, err := dialUpstream(connCtx, d.opts.Address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("socks upstream: %w", err)
+	}
+	if err := d.connect(conn, remoteAddr); err != nil {
+		helper.Close(conn)
+		return nil, fmt.Errorf("socks upstream: %s: %w", d.opts.Address, err)
+	}
+	return proxy.NewDirectConnection(conn), nil
+}
+
+func (d *SocksDialer) connect(conn stdnet.Conn, remoteAddr net.Address) error {
+	_ = conn.SetDeadline(time.Now().Add(upstreamHandshakeTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+	// Method
+	methods := []byte{socks.MethodNoAuth}
+	if d.opts.Username != "" {
+		methods = append(methods, socks.MethodUserPassAuth)
+	}
+	if _, err := conn.Write(socks.NewMethodRequest(socks.VersionSocks5, methods).Bytes()); err != nil {
+		return fmt.Errorf("send method request. %w", err)
+	}
+	method, err := socks.ParseMethodReply(conn)
+	if err != nil {
+		return fmt.Errorf("parse method reply. %w", err)
+	}
+	if method.Ver != socks.VersionSocks5 {
+		return socks.ErrNotSupportVersion
+	}
+	switch method.Method {
+	case socks.MethodNoAuth:
+		// continue
+	case socks.MethodUserPassAuth:
+		if d.opts.Username == "" {
+			return errors.New("upstream requires user/pass auth")
+		}
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %d", socks.ErrNotSupportMethod, method.Method)
+	}
+	// Connect
+	request := socks.Request{
+		Version: socks.VersionSocks5,
+		Command: socks.CommandConnect,
+		DstAddr: toAddrSpec(remoteAddr),
+	}
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return fmt.Errorf("send request. %w", err)
+	}
+	reply, err := socks.ParseReply(conn)
+	if err != nil {
+		return fmt.Errorf("parse reply. %w", err)
+	}
+	switch reply.Response {
+	case socks.RepSuccess:
+		return nil
+	case socks.RepRuleFailure, socks.RepNetworkUnreachable, socks.RepHostUnreachable, socks.RepConnectionRefused, socks.RepTTLExpired:
+		return fmt.Errorf("connect %s: %s. %w", remoteAddr.Addrport(), replyText(reply.Response), ErrTargetFailure)
+	default:
+		return fmt.Errorf("connect %s: %s", remoteAddr.Addrport(), replyText(reply.Response))
+	}
+}
+
+func (d *SocksDialer) authenticate(conn stdnet.Conn) error {
+	request := socks.NewUserPassRequest(socks.UserPassAuthVersion, []byte(d.opts.Username), []byte(d.opts.Password))
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return fmt.Errorf("send auth request. %w", err)
+	}
+	reply, err := socks.ParseUserPassReply(conn)
+	if err != nil {
+		return fmt.Errorf("parse auth reply. %w", err)
+	}
+	if reply.Status != socks.AuthSuccess {
+		return errors.New("authenticate failed")
+	}
+	return nil
+}
+
+func toAddrSpec(addr net.Address) socks.AddrSpec {
+	switch addr.Family {
+	case net.AddressFamilyDomain:
+		return socks.AddrSpec{FQDN: addr.Domain, Port: addr.Port, AddrType: socks.ATYPDomain}
+	case net.AddressFamilyIPv6:
+		return socks.AddrSpec{IP: addr.IP, Port: addr.Port, AddrType: socks.ATYPIPv6}
+	default:
+		return socks.AddrSpec{IP: addr.IP, Port: addr.Port, AddrType: socks.ATYPIPv4}
+	}
+}
+
+// replyText 返回响应状态的描述，与系统网络错误的描述保持一致，便于监听器映射响应状态
+func replyText(rep uint8) string {
+	switch rep {
+	case socks.RepRuleFailure:
+		return "connection not allowed by ruleset"
+	case socks.RepNetworkUnreachable:
+		return "network is unreachable"
+	case socks.RepHostUnreachable:
+		return "host is unreachable"
+	case socks.RepConnectionRefused:
+		return "connection refused"
+	case socks.RepTTLExpired:
+		return "ttl expired"
+	case socks.RepCommandNotSupported:
+		return "command not supported"
+	case socks.RepAddrTypeNotSupported:
+		return "address type not supported"
+	default:
+		return fmt.Sprintf("general failure(%d)", rep)
+	}
+}
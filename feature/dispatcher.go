@@ -12,6 +12,7 @@ import (
 	"github.com/fluxproxy/fluxproxy/internal"
 	"github.com/fluxproxy/fluxproxy/net"
 	"github.com/sirupsen/logrus"
+	stdnet "net"
 	"strings"
 	"time"
 )
@@ -61,9 +62,11 @@ func (d *Dispatcher) Dispatch(local proxy.Connector) {
 	}(local.Context().Value(internal.CtxKeyStartTime).(time.Time))
 
 	// Ruleset
+	principal := proxy.ContextPrincipal(local.Context())
 	ruErr := UseRuleset().Allow(local.Context(), proxy.Permit{
 		Source:      local.Source(),
 		Destination: destAddr,
+		Principal:   principal,
 	})
 	ruErr = d.callHook(local, internal.CtxHookAfterRuleset, ruErr, "ruleset")
 	if ruErr != nil {
@@ -73,26 +76,52 @@ func (d *Dispatcher) Dispatch(local proxy.Connector) {
 		}
 	}
 
-	// Resolve
-	destIPAddr, rvErr := UseResolver().Resolve(local.Context(), destAddr)
-	rvErr = d.callHook(local, internal.CtxHookAfterResolve, rvErr, "resolve")
-	if rvErr != nil {
-		proxy.Logger(local.Context()).Errorf("disp: resolve: %s", rvErr)
-		return
+	// Route
+	outbound := d.lookupDialer(local)
+
+	// Resolve: 由上游代理解析域名时，跳过本地解析
+	dialAddr := destAddr
+	dialCtx := local.Context()
+	if !(destAddr.IsDomain() && isRemoteResolve(outbound)) {
+		resolveCtx := context.WithValue(local.Context(), internal.CtxKeyOutbound, outbound)
+		destIPs, rvErr := UseResolver().Resolve(resolveCtx, destAddr)
+		rvErr = d.callHook(local, internal.CtxHookAfterResolve, rvErr, "resolve")
+		if rvErr != nil {
+			proxy.Logger(local.Context()).Errorf("disp: resolve: %s", rvErr)
+			return
+		}
+		// Ruleset: 以解析后的 IP 地址再次检查，避免通过域名绕过 IP 规则；
+		// 由上游代理解析的域名不在本地解析，不执行此检查。
+		if destAddr.IsDomain() {
+			allowed, rrErr := d.allowResolved(local, destAddr, destIPs, principal)
+			rrErr = d.callHook(local, internal.CtxHookAfterResolvedRuleset, rrErr, "resolved-ruleset")
+			if rrErr != nil && !errors.Is(rrErr, proxy.ErrNoRulesetMatched) {
+				proxy.Logger(local.Context()).Errorf("disp: resolved-ruleset: %s", rrErr)
+				return
+			}
+			destIPs = allowed
+		}
+		dialAddr = net.Address{
+			Network: destAddr.Network,
+			Family:  net.ToAddressFamily(destIPs[0]),
+			IP:      destIPs[0],
+			Port:    destAddr.Port,
+		}
+		dialCtx = proxy.ContextWithDialIPs(dialCtx, destIPs)
+	} else if d.opts.Verbose {
+		proxy.Logger(local.Context()).
+			WithField("outbound", outbound.Name()).
+			Infof("disp: resolve: remote, skip resolved-ruleset")
 	}
 
 	// Dial
 	if d.opts.Verbose {
 		proxy.Logger(local.Context()).
-			WithField("ipaddr", destIPAddr.String()).
+			WithField("ipaddr", dialAddr.Addr()).
+			WithField("outbound", outbound.Name()).
 			Infof("disp: dial")
 	}
-	remote, dlErr := d.lookupDialer(destAddr).Dial(local.Context(), net.Address{
-		Network: destAddr.Network,
-		Family:  net.ToAddressFamily(destIPAddr),
-		IP:      destIPAddr,
-		Port:    destAddr.Port,
-	})
+	remote, dlErr := outbound.Dial(dialCtx, dialAddr)
 	defer helper.Close(remote)
 	dlErr = d.callHook(local, internal.CtxHookAfterDial, dlErr, "dial")
 	if dlErr != nil {
@@ -106,13 +135,28 @@ func (d *Dispatcher) Dispatch(local proxy.Connector) {
 	d.onTailError(local.Context(), cnErr)
 }
 
-func (d *Dispatcher) Authenticate(ctx context.Context, authentication proxy.Authentication) error {
+// allowResolved 以解析得到的地址再次检查访问规则，返回未被拒绝的地址
+func (d *Dispatcher) allowResolved(local proxy.Connector, destAddr net.Address, ips []stdnet.IP, principal proxy.Principal) ([]stdnet.IP, error) {
+	var onDeny func(ip stdnet.IP, err error)
+	if d.opts.Verbose {
+		onDeny = func(ip stdnet.IP, err error) {
+			proxy.Logger(local.Context()).Infof("disp: resolved-ruleset: skip %s: %s", ip, err)
+		}
+	}
+	return UseRuleset().AllowResolved(local.Context(), proxy.Permit{
+		Source:      local.Source(),
+		Destination: destAddr,
+		Principal:   principal,
+	}, ips, onDeny)
+}
+
+func (d *Dispatcher) Authenticate(ctx context.Context, authentication proxy.Authentication) (proxy.Principal, error) {
 	assert.MustTrue(authentication.Authenticate != proxy.AuthenticateAllow, "authenticate is invalid")
-	auErr := d.lookupAuthenticator(authentication).Authenticate(ctx, authentication)
+	principal, auErr := d.lookupAuthenticator(authentication).Authenticate(ctx, authentication)
 	if auErr != nil {
 		proxy.Logger(ctx).Errorf("disp: authenticate: %s", auErr)
 	}
-	return auErr
+	return principal, auErr
 }
 
 func (d *Dispatcher) RegisterAuthenticator(kind proxy.Authenticate, authenticator proxy.Authenticator) {
@@ -124,10 +168,39 @@ func (d *Dispatcher) RegisterAuthenticator(kind proxy.Authenticate, authenticato
 	logrus.Infof("disp: register:authenticator: %s", kind)
 }
 
-func (d *Dispatcher) lookupDialer(addr net.Address) proxy.Dialer {
+func (d *Dispatcher) RegisterDialer(outbound proxy.Dialer) {
+	assert.MustNotNil(outbound, "dialer is nil")
+	_, exists := d.dialer[outbound.Name()]
+	assert.MustFalse(exists, "dialer is already exists: %s", outbound.Name())
+	d.dialer[outbound.Name()] = outbound
+	logrus.Infof("disp: register:dialer: %s", outbound.Name())
+}
+
+func (d *Dispatcher) GetDialer(name string) (proxy.Dialer, bool) {
+	v, ok := d.dialer[name]
+	return v, ok
+}
+
+func (d *Dispatcher) lookupDialer(local proxy.Connector) proxy.Dialer {
+	name := UseRouter().Route(local.Context(), proxy.Permit{
+		Source:      local.Source(),
+		Destination: local.Destination(),
+		Principal:   proxy.ContextPrincipal(local.Context()),
+	})
+	if v, ok := d.dialer[name]; ok {
+		return v
+	}
+	proxy.Logger(local.Context()).Warnf("disp: dialer not found: %s", name)
 	return d.dialer[dialer.DIRECT]
 }
 
+func isRemoteResolve(outbound proxy.Dialer) bool {
+	if v, ok := outbound.(proxy.RemoteResolver); ok {
+		return v.RemoteResolve()
+	}
+	return false
+}
+
 func (d *Dispatcher) callHook(local proxy.Connector, hookKey any, preErr error, phase string) error {
 	if hook, ok := local.HookFunc(hookKey); ok {
 		if hErr := hook(local.Context(), preErr); hErr != nil {
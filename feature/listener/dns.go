@@ -0,0 +1,400 @@
+package listener
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature"
+	"github.com/fluxproxy/fluxproxy/feature/resolver"
+	"github.com/fluxproxy/fluxproxy/feature/ruleset"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/internal"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	stdnet "net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	_ proxy.Listener = (*DnsListener)(nil)
+)
+
+// block mode
+const (
+	// DnsBlockNXDomain 被规则拒绝的域名应答 NXDOMAIN
+	DnsBlockNXDomain = "nxdomain"
+	// DnsBlockZero 被规则拒绝的域名应答 0.0.0.0 或 ::
+	DnsBlockZero = "zero"
+)
+
+const (
+	dnsBlockTTL       = 60
+	dnsMinUDPSize     = 512
+	dnsMaxUDPSize     = 1232
+	dnsTcpIdleTimeout = 10 * time.Second
+	// dnsMaxUdpInflight 同时处理的 UDP 查询上限，超出时丢弃查询报文
+	dnsMaxUdpInflight = 256
+)
+
+type DnsOptions struct {
+	// Block 被规则拒绝的域名的应答方式：nxdomain, zero
+	Block string
+}
+
+// DnsListener DNS 服务监听器，同时监听 UDP 与 TCP 端口；A/AAAA 记录由 CacheResolver 解析，
+// 与代理连接共用缓存、hosts 及访问规则。查询不含端口、用户等连接信息，仅使用按目标地址匹配的规则：
+// 以域名规则检查查询的域名，以域名及目标 IP 规则检查解析得到的地址。
+type DnsListener struct {
+	opts            DnsOptions
+	listenerOpts    proxy.ListenerOptions
+	domainRuleset   *feature.MultiRuleset
+	resolvedRuleset *feature.MultiRuleset
+}
+
+func NewDnsListener(listenerOpts proxy.ListenerOptions, dnsOpts DnsOptions) *DnsListener {
+	return &DnsListener{
+		listenerOpts: listenerOpts,
+		opts:         dnsOpts,
+	}
+}
+
+func (l *DnsListener) Init(runCtx context.Context) error {
+	if l.listenerOpts.Port <= 0 {
+		return fmt.Errorf("dns: invalid port: %d", l.listenerOpts.Port)
+	}
+	switch l.opts.Block {
+	case DnsBlockNXDomain, DnsBlockZero:
+	default:
+		return fmt.Errorf("dns: invalid block mode: %s", l.opts.Block)
+	}
+	l.domainRuleset = feature.UseRuleset().Select(ruleset.IsDomainRuleset)
+	l.resolvedRuleset = feature.UseRuleset().Select(ruleset.IsDestinationRuleset)
+	return nil
+}
+
+func (l *DnsListener) Listen(serveCtx context.Context) error {
+	addr := stdnet.JoinHostPort(l.listenerOpts.Address, strconv.Itoa(l.listenerOpts.Port))
+	logrus.Infof("dns: listen: %s", addr)
+	udpConn, uErr := stdnet.ListenPacket("udp", addr)
+	if uErr != nil {
+		return fmt.Errorf("listen udp %s. %w", addr, uErr)
+	}
+	tcpListener, tErr := stdnet.ListenTCP("tcp", &stdnet.TCPAddr{IP: stdnet.ParseIP(l.listenerOpts.Address), Port: l.listenerOpts.Port})
+	if tErr != nil {
+		helper.Close(udpConn)
+		return fmt.Errorf("listen tcp %s. %w", addr, tErr)
+	}
+	serveCtx, serveCancel := context.WithCancel(serveCtx)
+	defer serveCancel()
+	errs := make(chan error, 2)
+	go func() {
+		errs <- l.serveUdp(serveCtx, udpConn)
+	}()
+	go func() {
+		errs <- tcpServeWith(serveCtx, tcpListener, func(tcpConn *stdnet.TCPConn) {
+			l.serveTcp(internal.SetupTcpContextLogger(serveCtx, tcpConn), tcpConn)
+		})
+	}()
+	err := <-errs
+	serveCancel()
+	<-errs
+	return err
+}
+
+func (l *DnsListener) serveUdp(serveCtx context.Context, conn stdnet.PacketConn) error {
+	go func() {
+		<-serveCtx.Done()
+		_ = conn.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	inflight := make(chan struct{}, dnsMaxUdpInflight)
+	for {
+		n, from, rdErr := conn.ReadFrom(buf)
+		if rdErr != nil {
+			select {
+			case <-serveCtx.Done():
+				return serveCtx.Err()
+			default:
+				return fmt.Errorf("read udp. %w", rdErr)
+			}
+		}
+		udpAddr, ok := from.(*stdnet.UDPAddr)
+		if !ok {
+			continue
+		}
+		select {
+		case inflight <- struct{}{}:
+		default:
+			if l.listenerOpts.Verbose {
+				logrus.Warnf("dns: too many queries, drop: %s", udpAddr)
+			}
+			continue
+		}
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		go func() {
+			defer func() { <-inflight }()
+			connCtx := internal.SetupUdpContextLogger(serveCtx, udpAddr)
+			srcAddr := net.ParseIPAddr(net.NetworkUDP, udpAddr.IP)
+			srcAddr.Port = udpAddr.Port
+			if resp := l.handle(connCtx, srcAddr, query, true); resp != nil {
+				_, _ = conn.WriteTo(resp, udpAddr)
+			}
+		}()
+	}
+}
+
+// serveTcp 处理 TCP 连接上的多个查询，报文以 2 字节长度为前缀
+func (l *DnsListener) serveTcp(connCtx context.Context, conn *stdnet.TCPConn) {
+	defer helper.Close(conn)
+	srcAddr := parseRemoteAddress(conn.RemoteAddr().String())
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(dnsTcpIdleTimeout))
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		resp := l.handle(connCtx, srcAddr, query, false)
+		if resp == nil {
+			return
+		}
+		packet := make([]byte, 2+len(resp))
+		binary.BigEndian.PutUint16(packet, uint16(len(resp)))
+		copy(packet[2:], resp)
+		if _, err := conn.Write(packet); err != nil {
+			return
+		}
+	}
+}
+
+// handle 处理一个查询报文，返回应答报文；报文无法解析时返回 nil
+func (l *DnsListener) handle(connCtx context.Context, srcAddr net.Address, query []byte, isUdp bool) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+	reply := dnsReply{header: dnsmessage.Header{
+		ID:                 header.ID,
+		Response:           true,
+		OpCode:             header.OpCode,
+		RecursionDesired:   header.RecursionDesired,
+		RecursionAvailable: true,
+	}}
+	questions, err := parser.AllQuestions()
+	if err != nil || len(questions) != 1 {
+		reply.header.RCode = dnsmessage.RCodeFormatError
+		return reply.build(dnsMinUDPSize)
+	}
+	reply.question = &questions[0]
+	udpSize := dnsMinUDPSize
+	if err := parser.SkipAllAnswers(); err == nil {
+		if err := parser.SkipAllAuthorities(); err == nil {
+			if additionals, err := parser.AllAdditionals(); err == nil {
+				for _, rr := range additionals {
+					if rr.Header.Type == dnsmessage.TypeOPT {
+						reply.edns = true
+						udpSize = min(max(int(rr.Header.Class), dnsMinUDPSize), dnsMaxUDPSize)
+					}
+				}
+			}
+		}
+	}
+	if !isUdp {
+		udpSize = 0
+	}
+	if header.OpCode != 0 || reply.question.Class != dnsmessage.ClassINET {
+		reply.header.RCode = dnsmessage.RCodeNotImplemented
+		return reply.build(udpSize)
+	}
+	l.answer(connCtx, srcAddr, &reply)
+	return reply.build(udpSize)
+}
+
+func (l *DnsListener) answer(connCtx context.Context, srcAddr net.Address, reply *dnsReply) {
+	qtype := reply.question.Type
+	name := strings.ToLower(strings.TrimSuffix(reply.question.Name.String(), "."))
+	if l.listenerOpts.Verbose {
+		proxy.Logger(connCtx).WithField("type", qtype).Infof("dns: query: %s", name)
+	}
+	if qtype != dnsmessage.TypeA && qtype != dnsmessage.TypeAAAA && qtype != dnsmessage.TypeCNAME {
+		reply.header.RCode = dnsmessage.RCodeNotImplemented
+		return
+	}
+	if name == "" {
+		reply.header.RCode = dnsmessage.RCodeRefused
+		return
+	}
+	// Ruleset: 以域名规则检查查询的域名
+	destAddr := net.ParseDomainAddr(net.NetworkUDP, name)
+	permit := proxy.Permit{
+		Source:      srcAddr,
+		Destination: destAddr,
+	}
+	if ruErr := l.domainRuleset.Allow(connCtx, permit); ruErr != nil && !errors.Is(ruErr, proxy.ErrNoRulesetMatched) {
+		if l.listenerOpts.Verbose {
+			proxy.Logger(connCtx).Infof("dns: block: %s", ruErr)
+		}
+		l.block(reply)
+		return
+	}
+	// CNAME
+	if qtype == dnsmessage.TypeCNAME {
+		cname, ttl, err := feature.UseResolver().LookupCNAME(connCtx, name)
+		if err != nil {
+			l.failure(connCtx, reply, err)
+			return
+		}
+		if cname != "" {
+			reply.cname = cname
+			reply.ttl = ttl
+		}
+		return
+	}
+	// A/AAAA
+	ips, ttl, err := feature.UseResolver().Lookup(connCtx, destAddr)
+	if err != nil {
+		l.failure(connCtx, reply, err)
+		return
+	}
+	answers := make([]stdnet.IP, 0, len(ips))
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (qtype == dnsmessage.TypeA) {
+			answers = append(answers, ip)
+		}
+	}
+	// Ruleset: 以解析后的 IP 地址再次检查，仅应答未被拒绝的地址
+	if len(answers) > 0 {
+		var onDeny func(ip stdnet.IP, err error)
+		if l.listenerOpts.Verbose {
+			onDeny = func(ip stdnet.IP, err error) {
+				proxy.Logger(connCtx).Infof("dns: resolved-ruleset: skip %s: %s", ip, err)
+			}
+		}
+		allowed, rrErr := l.resolvedRuleset.AllowResolved(connCtx, permit, answers, onDeny)
+		if rrErr != nil {
+			if l.listenerOpts.Verbose {
+				proxy.Logger(connCtx).Infof("dns: block: %s", rrErr)
+			}
+			l.block(reply)
+			return
+		}
+		answers = allowed
+	}
+	reply.ips = answers
+	reply.ttl = ttl
+}
+
+func (l *DnsListener) block(reply *dnsReply) {
+	if l.opts.Block == DnsBlockNXDomain {
+		reply.header.RCode = dnsmessage.RCodeNameError
+		return
+	}
+	switch reply.question.Type {
+	case dnsmessage.TypeA:
+		reply.ips = []stdnet.IP{stdnet.IPv4zero}
+	case dnsmessage.TypeAAAA:
+		reply.ips = []stdnet.IP{stdnet.IPv6zero}
+	}
+	reply.ttl = dnsBlockTTL * time.Second
+}
+
+// failure 按解析错误设置应答码：域名不存在为 NXDOMAIN，没有地址记录时返回空应答，其它为 SERVFAIL
+func (l *DnsListener) failure(connCtx context.Context, reply *dnsReply, err error) {
+	var dnsErr *stdnet.DNSError
+	switch {
+	case errors.Is(err, resolver.ErrNotFound), errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		reply.header.RCode = dnsmessage.RCodeNameError
+	case errors.Is(err, resolver.ErrNoAddress):
+		reply.header.RCode = dnsmessage.RCodeSuccess
+	default:
+		reply.header.RCode = dnsmessage.RCodeServerFailure
+		proxy.Logger(connCtx).Errorf("dns: resolve: %s", err)
+	}
+}
+
+////
+
+// dnsReply 应答报文的内容
+type dnsReply struct {
+	header   dnsmessage.Header
+	question *dnsmessage.Question
+	edns     bool
+	cname    string
+	ips      []stdnet.IP
+	ttl      time.Duration
+}
+
+// build 生成应答报文；UDP 应答超过 udpSize 时，去掉应答记录并设置截断标志，由客户端使用 TCP 重试
+func (r *dnsReply) build(udpSize int) []byte {
+	msg, err := r.pack(false)
+	if err == nil && udpSize > 0 && len(msg) > udpSize {
+		msg, err = r.pack(true)
+	}
+	if err != nil {
+		logrus.Errorf("dns: build reply. %s", err)
+		return nil
+	}
+	return msg
+}
+
+func (r *dnsReply) pack(truncated bool) ([]byte, error) {
+	header := r.header
+	header.Truncated = truncated
+	builder := dnsmessage.NewBuilder(make([]byte, 0, dnsMinUDPSize), header)
+	builder.EnableCompression()
+	if r.question != nil {
+		_ = builder.StartQuestions()
+		if err := builder.Question(*r.question); err != nil {
+			return nil, err
+		}
+	}
+	if !truncated && r.question != nil {
+		_ = builder.StartAnswers()
+		ttl := uint32(r.ttl / time.Second)
+		rh := dnsmessage.ResourceHeader{Name: r.question.Name, Class: dnsmessage.ClassINET, TTL: ttl}
+		if r.cname != "" {
+			cname, err := dnsmessage.NewName(r.cname + ".")
+			if err != nil {
+				return nil, err
+			}
+			if err := builder.CNAMEResource(rh, dnsmessage.CNAMEResource{CNAME: cname}); err != nil {
+				return nil, err
+			}
+		}
+		for _, ip := range r.ips {
+			if ip4 := ip.To4(); ip4 != nil {
+				var res dnsmessage.AResource
+				copy(res.A[:], ip4)
+				if err := builder.AResource(rh, res); err != nil {
+					return nil, err
+				}
+			} else {
+				var res dnsmessage.AAAAResource
+				copy(res.AAAA[:], ip.To16())
+				if err := builder.AAAAResource(rh, res); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if r.edns {
+		_ = builder.StartAdditionals()
+		var opt dnsmessage.ResourceHeader
+		_ = opt.SetEDNS0(dnsMaxUDPSize, dnsmessage.RCodeSuccess, false)
+		if err := builder.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Finish()
+}
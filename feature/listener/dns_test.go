@@ -0,0 +1,93 @@
+package listener
+
+import (
+	"context"
+	stdnet "net"
+	"testing"
+	"time"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature"
+	"github.com/fluxproxy/fluxproxy/feature/ruleset"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newTestDnsListener 初始化访问规则及解析器，其中包含不应影响 DNS 应答的端口、时间、用户及来源规则
+func newTestDnsListener(t *testing.T) *DnsListener {
+	web, err := net.ParsePortRange("80")
+	require.NoError(t, err)
+	tls, err := net.ParsePortRange("443")
+	require.NoError(t, err)
+	notWeb, err := ruleset.NewLogical(false, ruleset.LogicalNot, []proxy.Ruleset{ruleset.NewPort(true, false, []net.PortRange{web, tls})})
+	require.NoError(t, err)
+	everyday := [7]bool{true, true, true, true, true, true, true}
+	_, anySource, _ := stdnet.ParseCIDR("0.0.0.0/0")
+	blocked, err := ruleset.NewDomainMatcher([]string{"blocked.test"})
+	require.NoError(t, err)
+	special, err := ruleset.NewSpecialRanges([]string{ruleset.SpecialLoopback})
+	require.NoError(t, err)
+	feature.InitMultiRuleset([]proxy.Ruleset{
+		special,
+		notWeb,
+		ruleset.NewSchedule(false, time.UTC, everyday, nil),
+		ruleset.NewUser(false, nil, []string{"contractors"}),
+		ruleset.NewIPNet(false, true, []stdnet.IPNet{*anySource}),
+		ruleset.NewDomain(false, false, blocked),
+	})
+	resolver := feature.InitResolverWith(feature.Options{CacheSize: 16, CacheTTL: time.Minute})
+	resolver.Set("allowed.test", stdnet.ParseIP("10.0.0.1"))
+	resolver.Set("blocked.test", stdnet.ParseIP("10.0.0.2"))
+	resolver.Set("loopback.test", stdnet.ParseIP("127.0.0.1"))
+	l := NewDnsListener(proxy.ListenerOptions{Port: 53}, DnsOptions{Block: DnsBlockNXDomain})
+	require.NoError(t, l.Init(context.Background()))
+	return l
+}
+
+func TestDnsListenerRuleset(t *testing.T) {
+	l := newTestDnsListener(t)
+	tests := []struct {
+		name      string
+		wantRCode dnsmessage.RCode
+		wantIPs   []string
+	}{
+		{"allowed.test", dnsmessage.RCodeSuccess, []string{"10.0.0.1"}},
+		{"blocked.test", dnsmessage.RCodeNameError, nil},
+		{"loopback.test", dnsmessage.RCodeNameError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1, RecursionDesired: true})
+			_ = builder.StartQuestions()
+			require.NoError(t, builder.Question(dnsmessage.Question{
+				Name:  dnsmessage.MustNewName(tt.name + "."),
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET,
+			}))
+			query, err := builder.Finish()
+			require.NoError(t, err)
+			srcAddr := net.ParseIPAddr(net.NetworkUDP, stdnet.ParseIP("192.168.1.10"))
+			resp := l.handle(context.Background(), srcAddr, query, true)
+			require.NotNil(t, resp)
+			var msg dnsmessage.Message
+			require.NoError(t, msg.Unpack(resp))
+			assert.Equal(t, tt.wantRCode, msg.Header.RCode)
+			var ips []string
+			for _, answer := range msg.Answers {
+				if a, ok := answer.Body.(*dnsmessage.AResource); ok {
+					ips = append(ips, stdnet.IP(a.A[:]).String())
+				}
+			}
+			assert.Equal(t, tt.wantIPs, ips)
+		})
+	}
+}
+
+func TestDnsListenerRulesetSelect(t *testing.T) {
+	l := newTestDnsListener(t)
+	permit := proxy.Permit{Destination: net.ParseDomainAddr(net.NetworkUDP, "example.test")}
+	assert.ErrorIs(t, l.domainRuleset.Allow(context.Background(), permit), proxy.ErrNoRulesetMatched)
+	assert.Error(t, feature.UseRuleset().Allow(context.Background(), permit))
+}
@@ -1,30 +1,44 @@
 package listener
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/bytepowered/assert"
 	proxy "github.com/fluxproxy/fluxproxy"
 	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/sirupsen/logrus"
 	stdnet "net"
+	"sync"
 	"time"
 )
 
 func parseRemoteAddress(remoteAddr string) net.Address {
-	host, _, hpErr := stdnet.SplitHostPort(remoteAddr)
-	assert.MustNil(hpErr, "http: parse host port error: %s", hpErr)
-	srcAddr, _ := net.ParseAddress(net.NetworkTCP, host)
-	assert.MustTrue(srcAddr.IsIP(), "http: srcAddr is not ip")
+	srcAddr, hpErr := net.ParseAddress(net.NetworkTCP, remoteAddr)
+	assert.MustNil(hpErr, "listener: parse remote address error: %s", hpErr)
+	assert.MustTrue(srcAddr.IsIP(), "listener: remote address is not ip: %s", remoteAddr)
 	return srcAddr
 }
 
-func tcpListenWith(serveCtx context.Context, opts proxy.ListenerOptions, connHandler func(*stdnet.TCPConn)) error {
+func tcpListenWith(serveCtx context.Context, opts proxy.ListenerOptions, connHandler func(stdnet.Conn)) error {
 	addr := &stdnet.TCPAddr{IP: stdnet.ParseIP(opts.Address), Port: opts.Port}
 	listener, lErr := stdnet.ListenTCP("tcp", addr)
 	if lErr != nil {
 		return fmt.Errorf("listen %s. %w", addr, lErr)
 	}
+	return tcpServeWith(serveCtx, listener, func(tcpConn *stdnet.TCPConn) {
+		conn, ppErr := acceptProxyProtocol(tcpConn, opts)
+		if ppErr != nil {
+			_ = tcpConn.Close()
+			logrus.Errorf("listen %s: %s", addr, ppErr)
+			return
+		}
+		connHandler(conn)
+	})
+}
+
+func tcpServeWith(serveCtx context.Context, listener *stdnet.TCPListener, connHandler func(*stdnet.TCPConn)) error {
 	_ = listener.SetDeadline(time.Time{})
 	go func() {
 		<-serveCtx.Done()
@@ -57,3 +71,75 @@ func tcpListenWith(serveCtx context.Context, opts proxy.ListenerOptions, connHan
 		go connHandler(conn.(*stdnet.TCPConn))
 	}
 }
+
+////
+
+var (
+	_ stdnet.Conn     = (*peekConn)(nil)
+	_ stdnet.Listener = (*connListener)(nil)
+)
+
+// peekConn 支持预读首部字节的连接，预读的数据仍可被后续的 Read 读取
+type peekConn struct {
+	stdnet.Conn
+	reader *bufio.Reader
+}
+
+func newPeekConn(conn stdnet.Conn) *peekConn {
+	return &peekConn{
+		Conn:   conn,
+		reader: bufio.NewReaderSize(conn, 16),
+	}
+}
+
+func (c *peekConn) Peek(n int) ([]byte, error) {
+	return c.reader.Peek(n)
+}
+
+func (c *peekConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// connListener 将外部接收的连接，以 Listener 的方式提供给 http.Server 等服务
+type connListener struct {
+	addr  stdnet.Addr
+	conns chan stdnet.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(addr stdnet.Addr) *connListener {
+	return &connListener{
+		addr:  addr,
+		conns: make(chan stdnet.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *connListener) Push(conn stdnet.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		_ = conn.Close()
+	}
+}
+
+func (l *connListener) Accept() (stdnet.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, stdnet.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() stdnet.Addr {
+	return l.addr
+}
@@ -2,12 +2,14 @@ package listener
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/bytepowered/assert"
 	"github.com/fluxproxy/fluxproxy"
 	"github.com/fluxproxy/fluxproxy/feature/connector"
+	"github.com/fluxproxy/fluxproxy/feature/dialer"
 	"github.com/fluxproxy/fluxproxy/helper"
 	"github.com/fluxproxy/fluxproxy/internal"
 	"github.com/fluxproxy/fluxproxy/net"
@@ -24,12 +26,14 @@ var (
 )
 
 type HttpOptions struct {
+	Tls TlsOptions
 }
 
 type HttpListener struct {
 	opts         HttpOptions
 	listenerOpts proxy.ListenerOptions
 	dispatcher   proxy.Dispatcher
+	tlsConfig    *tls.Config
 }
 
 func NewHttpListener(
@@ -48,6 +52,14 @@ func (l *HttpListener) Init(runCtx context.Context) error {
 	if l.listenerOpts.Port <= 0 {
 		return fmt.Errorf("http: invalid port: %d", l.listenerOpts.Port)
 	}
+	if l.opts.Tls.Enabled() {
+		tlsConfig, err := newTlsConfig(runCtx, l.opts.Tls)
+		if err != nil {
+			return fmt.Errorf("http: %w", err)
+		}
+		l.tlsConfig = tlsConfig
+		logrus.Infof("http: tls enabled, cert: %s", l.opts.Tls.CertFile)
+	}
 	return nil
 }
 
@@ -58,7 +70,38 @@ func (l *HttpListener) Listen(serveCtx context.Context) error {
 	} else {
 		logrus.Infof("http: listen(no-auth): %s", addr)
 	}
-	httpServer := &http.Server{
+	// 由 TCP 监听循环接收连接（并处理 PROXY protocol 首部），再交由 http.Server 服务
+	httpConns := l.serveWith(serveCtx, addr)
+	return tcpListenWith(serveCtx, l.listenerOpts, httpConns.Push)
+}
+
+// serveWith 启动以 connListener 为连接来源的 http.Server，随 serveCtx 结束而关闭
+func (l *HttpListener) serveWith(serveCtx context.Context, addr string) *connListener {
+	httpConns := newConnListener(&stdnet.TCPAddr{IP: stdnet.ParseIP(l.listenerOpts.Address), Port: l.listenerOpts.Port})
+	httpServer := l.newServer(serveCtx, addr)
+	go func() {
+		<-serveCtx.Done()
+		_ = httpServer.Shutdown(serveCtx)
+	}()
+	go func() {
+		var err error
+		if l.tlsConfig != nil {
+			// 禁用 HTTP/2：代理的 CONNECT 需要劫持 HTTP/1.1 连接
+			httpServer.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+			// 证书由 TLS 配置动态提供，不使用 ServeTLS 加载证书文件
+			err = httpServer.Serve(tls.NewListener(httpConns, l.tlsConfig))
+		} else {
+			err = httpServer.Serve(httpConns)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("http: serve: %s", err)
+		}
+	}()
+	return httpConns
+}
+
+func (l *HttpListener) newServer(serveCtx context.Context, addr string) *http.Server {
+	return &http.Server{
 		Addr:    addr,
 		Handler: http.HandlerFunc(l.serveHandler),
 		BaseContext: func(_ stdnet.Listener) context.Context {
@@ -68,11 +111,6 @@ func (l *HttpListener) Listen(serveCtx context.Context) error {
 			return internal.SetupTcpContextLogger(connCtx, conn)
 		},
 	}
-	go func() {
-		<-serveCtx.Done()
-		_ = httpServer.Shutdown(serveCtx)
-	}()
-	return httpServer.ListenAndServe()
 }
 
 func (l *HttpListener) serveHandler(rw http.ResponseWriter, r *http.Request) {
@@ -97,12 +135,15 @@ func (l *HttpListener) handleConnectStream(rw http.ResponseWriter, r *http.Reque
 	srcAddr := parseRemoteAddress(r.RemoteAddr)
 
 	// Authenticate
+	ctx := r.Context()
 	if l.listenerOpts.Auth {
-		auErr := dispatcher.Authenticate(r.Context(), l.parseProxyAuthorization(r.Header, srcAddr))
+		auth := l.parseProxyAuthorization(r.Header, srcAddr)
+		principal, auErr := dispatcher.Authenticate(ctx, auth)
 		if auErr != nil {
 			_, _ = hiConn.Write([]byte("HTTP/1.1 401 Unauthorized\r\n\r\n"))
 			return
 		}
+		ctx = proxy.ContextWithPrincipal(ctx, principal)
 	}
 	l.removeHopByHopHeaders(r.Header)
 
@@ -113,9 +154,10 @@ func (l *HttpListener) handleConnectStream(rw http.ResponseWriter, r *http.Reque
 	}
 
 	// Dispatch
-	ctx := internal.ContextWithHooks(r.Context(), map[any]proxy.HookFunc{
-		internal.CtxHookAfterRuleset: l.withRulesetHook(hiConn),
-		internal.CtxHookAfterDial:    l.withDialedHook(hiConn, r),
+	ctx = internal.ContextWithHooks(ctx, map[any]proxy.HookFunc{
+		internal.CtxHookAfterRuleset:         l.withRulesetHook(hiConn),
+		internal.CtxHookAfterResolvedRuleset: l.withRulesetHook(hiConn),
+		internal.CtxHookAfterDial:            l.withDialedHook(hiConn, r),
 	})
 	stream := connector.NewStreamConnector(ctx, hiConn, destAddr, srcAddr)
 	dispatcher.Dispatch(stream)
@@ -133,12 +175,15 @@ func (l *HttpListener) handlePlainRequest(rw http.ResponseWriter, r *http.Reques
 	srcAddr := parseRemoteAddress(r.RemoteAddr)
 
 	// Authenticate
+	ctx := r.Context()
 	if l.listenerOpts.Auth {
-		auErr := dispatcher.Authenticate(r.Context(), l.parseProxyAuthorization(r.Header, srcAddr))
+		auth := l.parseProxyAuthorization(r.Header, srcAddr)
+		principal, auErr := dispatcher.Authenticate(ctx, auth)
 		if auErr != nil {
 			rw.WriteHeader(http.StatusUnauthorized)
 			return
 		}
+		ctx = proxy.ContextWithPrincipal(ctx, principal)
 	}
 	l.removeHopByHopHeaders(r.Header)
 
@@ -149,9 +194,10 @@ func (l *HttpListener) handlePlainRequest(rw http.ResponseWriter, r *http.Reques
 	}
 
 	// Dispatch
-	ctx := internal.ContextWithHooks(r.Context(), map[any]proxy.HookFunc{
-		internal.CtxHookAfterRuleset: l.withRulesetHook(rw),
-		internal.CtxHookAfterDial:    l.withDialedHook(rw, r),
+	ctx = internal.ContextWithHooks(ctx, map[any]proxy.HookFunc{
+		internal.CtxHookAfterRuleset:         l.withRulesetHook(rw),
+		internal.CtxHookAfterResolvedRuleset: l.withRulesetHook(rw),
+		internal.CtxHookAfterDial:            l.withDialedHook(rw, r),
 	})
 	inst := connector.NewHttpConnector(rw, r.WithContext(ctx), destAddr, srcAddr)
 	dispatcher.Dispatch(inst)
@@ -176,13 +222,25 @@ func (*HttpListener) withRulesetHook(w io.Writer) proxy.HookFunc {
 }
 
 func (*HttpListener) withDialedHook(w io.Writer, r *http.Request) proxy.HookFunc {
-	return func(_ context.Context, _ error, _ ...any) error {
+	return func(_ context.Context, state error, _ ...any) error {
+		status := http.StatusOK
+		if errors.Is(state, dialer.ErrRejected) {
+			status = http.StatusForbidden
+		} else if state != nil {
+			status = http.StatusBadGateway
+		}
 		if rw, ok := w.(http.ResponseWriter); ok {
-			rw.WriteHeader(http.StatusOK)
+			// 普通请求成功时，由 HttpConnector 写入目标服务器的响应状态
+			if status != http.StatusOK {
+				rw.WriteHeader(status)
+			}
 		} else {
-			_, err := w.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
-			if err != nil {
-				return fmt.Errorf("http send response(established). %w", err)
+			line := "HTTP/1.1 200 Connection established\r\n\r\n"
+			if status != http.StatusOK {
+				line = fmt.Sprintf("HTTP/1.1 %d %s\r\n\r\n", status, http.StatusText(status))
+			}
+			if _, err := w.Write([]byte(line)); err != nil {
+				return fmt.Errorf("http send response(dialed). %w", err)
 			}
 		}
 		return nil
@@ -0,0 +1,100 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/internal"
+	"github.com/fluxproxy/fluxproxy/statute/socks"
+	"github.com/sirupsen/logrus"
+	stdnet "net"
+	"strconv"
+	"time"
+)
+
+var (
+	_ proxy.Listener = (*MixedListener)(nil)
+)
+
+const (
+	mixedPeekTimeout = time.Second * 10
+	// tlsRecordHandshake TLS 握手记录的首字节
+	tlsRecordHandshake = 0x16
+)
+
+type MixedOptions struct {
+	Http  HttpOptions
+	Socks SocksOptions
+}
+
+// MixedListener 在同一端口上同时提供 Http 与 Socks(4/4a/5) 代理服务，
+// 根据客户端发送的首字节识别协议类型。
+type MixedListener struct {
+	opts         MixedOptions
+	listenerOpts proxy.ListenerOptions
+	dispatcher   proxy.Dispatcher
+	http         *HttpListener
+	socks        *SocksListener
+}
+
+func NewMixedListener(
+	listenerOpts proxy.ListenerOptions,
+	mixedOpts MixedOptions,
+	dispatcher proxy.Dispatcher,
+) *MixedListener {
+	return &MixedListener{
+		listenerOpts: listenerOpts,
+		opts:         mixedOpts,
+		dispatcher:   dispatcher,
+		http:         NewHttpListener(listenerOpts, mixedOpts.Http, dispatcher),
+		socks:        NewSocksListener(listenerOpts, mixedOpts.Socks, dispatcher),
+	}
+}
+
+func (l *MixedListener) Init(runCtx context.Context) error {
+	if l.listenerOpts.Port <= 0 {
+		return fmt.Errorf("mixed: invalid port: %d", l.listenerOpts.Port)
+	}
+	// 同一端口上无法区分 Http 与 Socks 的 TLS 连接
+	if l.opts.Http.Tls.Enabled() && l.opts.Socks.Tls.Enabled() {
+		return fmt.Errorf("mixed: tls can only be enabled for one of http and socks")
+	}
+	if err := l.http.Init(runCtx); err != nil {
+		return fmt.Errorf("mixed: %w", err)
+	}
+	if err := l.socks.Init(runCtx); err != nil {
+		return fmt.Errorf("mixed: %w", err)
+	}
+	return nil
+}
+
+func (l *MixedListener) Listen(serveCtx context.Context) error {
+	addr := stdnet.JoinHostPort(l.listenerOpts.Address, strconv.Itoa(l.listenerOpts.Port))
+	if l.listenerOpts.Auth {
+		logrus.Infof("mixed: listen: %s", addr)
+	} else {
+		logrus.Infof("mixed: listen(no-auth): %s", addr)
+	}
+	httpConns := l.http.serveWith(serveCtx, addr)
+	return tcpListenWith(serveCtx, l.listenerOpts, func(rawConn stdnet.Conn) {
+		conn := newPeekConn(rawConn)
+		// 客户端须在握手超时内发送首字节，避免空闲连接长期占用
+		_ = conn.SetReadDeadline(time.Now().Add(mixedPeekTimeout))
+		head, err := conn.Peek(1)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		_ = conn.SetReadDeadline(time.Time{})
+		switch {
+		case head[0] == socks.VersionSocks5, head[0] == socks.VersionSocks4:
+			connCtx := internal.SetupTcpContextLogger(serveCtx, conn)
+			l.socks.serveConn(connCtx, conn)
+		case head[0] == tlsRecordHandshake && l.socks.tlsConfig != nil:
+			connCtx := internal.SetupTcpContextLogger(serveCtx, conn)
+			l.socks.serveTlsConn(connCtx, conn)
+		default:
+			httpConns.Push(conn)
+		}
+	})
+}
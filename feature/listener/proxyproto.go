@@ -0,0 +1,58 @@
+package listener
+
+import (
+	"fmt"
+	proxy "github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/statute/proxyproto"
+	stdnet "net"
+	"time"
+)
+
+const (
+	proxyProtocolTimeout = time.Second * 10
+)
+
+var (
+	_ stdnet.Conn = (*proxyProtocolConn)(nil)
+)
+
+// proxyProtocolConn 以 PROXY protocol 首部携带的客户端地址作为 RemoteAddr
+type proxyProtocolConn struct {
+	stdnet.Conn
+	remoteAddr stdnet.Addr
+}
+
+func (c *proxyProtocolConn) RemoteAddr() stdnet.Addr {
+	return c.remoteAddr
+}
+
+// acceptProxyProtocol 读取可信来源连接的 PROXY protocol 首部；非可信来源的连接原样返回
+func acceptProxyProtocol(tcpConn *stdnet.TCPConn, opts proxy.ListenerOptions) (stdnet.Conn, error) {
+	if !opts.ProxyProtocol {
+		return tcpConn, nil
+	}
+	remoteAddr, ok := tcpConn.RemoteAddr().(*stdnet.TCPAddr)
+	if !ok || !isProxyProtocolTrusted(remoteAddr.IP, opts.ProxyProtocolTrusted) {
+		return tcpConn, nil
+	}
+	_ = tcpConn.SetReadDeadline(time.Now().Add(proxyProtocolTimeout))
+	header, err := proxyproto.ReadHeader(tcpConn)
+	_ = tcpConn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("proxy-protocol: %s. %w", remoteAddr, err)
+	}
+	// LOCAL 命令（如负载均衡器的健康检查）及未知协议，使用连接自身的地址
+	if header.Command == proxyproto.CommandLocal || header.Source == nil {
+		return tcpConn, nil
+	}
+	return &proxyProtocolConn{Conn: tcpConn, remoteAddr: header.Source}, nil
+}
+
+func isProxyProtocolTrusted(ip stdnet.IP, trusted []*stdnet.IPNet) bool {
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
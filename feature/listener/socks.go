@@ -1,15 +1,20 @@
 package listener
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/fluxproxy/fluxproxy"
 	"github.com/fluxproxy/fluxproxy/feature/connector"
+	"github.com/fluxproxy/fluxproxy/feature/dialer"
+	"github.com/fluxproxy/fluxproxy/helper"
 	"github.com/fluxproxy/fluxproxy/internal"
 	"github.com/fluxproxy/fluxproxy/net"
 	"github.com/fluxproxy/fluxproxy/statute/socks"
 	"github.com/sirupsen/logrus"
+	"io"
 	stdnet "net"
 	"strconv"
 	"strings"
@@ -20,12 +25,16 @@ var (
 )
 
 type SocksOptions struct {
+	Tls TlsOptions
 }
 
+type socksSendFunc func(conn stdnet.Conn, rep uint8) error
+
 type SocksListener struct {
 	opts         SocksOptions
 	listenerOpts proxy.ListenerOptions
 	dispatcher   proxy.Dispatcher
+	tlsConfig    *tls.Config
 }
 
 func NewSocksListener(
@@ -44,6 +53,14 @@ func (l *SocksListener) Init(ctx context.Context) error {
 	if l.listenerOpts.Port <= 0 {
 		return fmt.Errorf("socks: invalid port: %d", l.listenerOpts.Port)
 	}
+	if l.opts.Tls.Enabled() {
+		tlsConfig, err := newTlsConfig(ctx, l.opts.Tls)
+		if err != nil {
+			return fmt.Errorf("socks: %w", err)
+		}
+		l.tlsConfig = tlsConfig
+		logrus.Infof("socks: tls enabled, cert: %s", l.opts.Tls.CertFile)
+	}
 	return nil
 }
 
@@ -54,62 +71,153 @@ func (l *SocksListener) Listen(serveCtx context.Context) error {
 	} else {
 		logrus.Infof("socks: listen(no-auth): %s", addr)
 	}
-	return tcpListenWith(serveCtx, l.listenerOpts, func(tcpConn *stdnet.TCPConn) {
-		connCtx := internal.SetupTcpContextLogger(serveCtx, tcpConn)
-		if err := l.handshakeHeader(connCtx, tcpConn); err != nil {
-			_ = l.send(tcpConn, socks.RepConnectionRefused)
-			proxy.Logger(connCtx).Errorf("socks: header: %s", err)
+	return tcpListenWith(serveCtx, l.listenerOpts, func(conn stdnet.Conn) {
+		connCtx := internal.SetupTcpContextLogger(serveCtx, conn)
+		if l.tlsConfig == nil {
+			l.serveConn(connCtx, conn)
 			return
 		}
-		srcAddr := parseRemoteAddress(tcpConn.RemoteAddr().String())
-
-		// Authenticate
-		if l.listenerOpts.Auth {
-			if err := l.handshakeUserAuth(connCtx, tcpConn, l.dispatcher); err != nil {
-				proxy.Logger(connCtx).Errorf("socks: auth(user): %s", err)
-				return
-			}
-		} else {
-			if err := l.handshakeSkipAuth(connCtx, tcpConn, l.dispatcher); err != nil {
-				proxy.Logger(connCtx).Errorf("socks: auth(skip): %s", err)
-				return
-			}
-		}
+		l.serveTlsConn(connCtx, conn)
+	})
+}
+
+// serveTlsConn 完成 TLS 握手后，以 TLS 连接提供服务
+func (l *SocksListener) serveTlsConn(connCtx context.Context, conn stdnet.Conn) {
+	tlsConn := tls.Server(conn, l.tlsConfig)
+	hsCtx, hsCancel := context.WithTimeout(connCtx, tlsHandshakeTimeout)
+	defer hsCancel()
+	if err := tlsConn.HandshakeContext(hsCtx); err != nil {
+		helper.Close(conn)
+		proxy.Logger(connCtx).Errorf("socks: tls handshake: %s", err)
+		return
+	}
+	l.serveConn(connCtx, tlsConn)
+}
+
+// serveConn 根据首字节的协议版本，分派到 SOCKS5 或 SOCKS4/4a 的处理流程
+func (l *SocksListener) serveConn(connCtx context.Context, conn stdnet.Conn) {
+	defer helper.Close(conn)
+	version := []byte{0}
+	if _, err := io.ReadFull(conn, version); err != nil {
+		proxy.Logger(connCtx).Errorf("socks: read version: %s", err)
+		return
+	}
+	header := io.MultiReader(bytes.NewReader(version), conn)
+	switch version[0] {
+	case socks.VersionSocks5:
+		l.serveSocks5(connCtx, conn, header)
+	case socks.VersionSocks4:
+		l.serveSocks4(connCtx, conn, header)
+	default:
+		proxy.Logger(connCtx).Errorf("socks: header: %s: %d", socks.ErrNotSupportVersion, version[0])
+	}
+}
 
-		// Destination
-		request, prErr := socks.ParseRequest(tcpConn)
-		if prErr != nil {
-			_ = l.send(tcpConn, socks.RepAddrTypeNotSupported)
-			proxy.Logger(connCtx).Errorf("socks: auth(skip): %s", prErr)
+func (l *SocksListener) serveSocks5(connCtx context.Context, conn stdnet.Conn, header io.Reader) {
+	if err := l.handshakeHeader(connCtx, header); err != nil {
+		_ = l.send(conn, socks.RepConnectionRefused)
+		proxy.Logger(connCtx).Errorf("socks: header: %s", err)
+		return
+	}
+	srcAddr := parseRemoteAddress(conn.RemoteAddr().String())
+
+	// Authenticate
+	if l.listenerOpts.Auth {
+		principal, err := l.handshakeUserAuth(connCtx, conn, l.dispatcher)
+		if err != nil {
+			proxy.Logger(connCtx).Errorf("socks: auth(user): %s", err)
 			return
 		}
-		if request.Command != socks.CommandConnect {
-			_ = l.send(tcpConn, socks.RepCommandNotSupported)
+		connCtx = proxy.ContextWithPrincipal(connCtx, principal)
+	} else {
+		if err := l.handshakeSkipAuth(connCtx, conn, l.dispatcher); err != nil {
+			proxy.Logger(connCtx).Errorf("socks: auth(skip): %s", err)
 			return
 		}
-		var destAddr net.Address
-		if request.DstAddr.FQDN != "" {
-			destAddr = net.ParseDomainAddr(net.NetworkTCP, request.DstAddr.FQDN)
-		} else {
-			destAddr = net.ParseIPAddr(net.NetworkTCP, request.DstAddr.IP)
+	}
+
+	// Destination
+	request, prErr := socks.ParseRequest(conn)
+	if prErr != nil {
+		_ = l.send(conn, socks.RepAddrTypeNotSupported)
+		proxy.Logger(connCtx).Errorf("socks: request: %s", prErr)
+		return
+	}
+	switch request.Command {
+	case socks.CommandConnect:
+		// continue
+	case socks.CommandBind:
+		l.handleBind(connCtx, conn, request, srcAddr)
+		return
+	case socks.CommandAssociate:
+		l.handleAssociate(connCtx, conn, request, srcAddr)
+		return
+	default:
+		_ = l.send(conn, socks.RepCommandNotSupported)
+		return
+	}
+	destAddr := parseDestAddress(net.NetworkTCP, request.DstAddr)
+	if l.listenerOpts.Verbose {
+		proxy.Logger(connCtx).WithField("dest", destAddr).Infof("socks: connect")
+	}
+
+	// Dispatch
+	connCtx = internal.ContextWithHooks(connCtx, map[any]proxy.HookFunc{
+		internal.CtxHookAfterRuleset:         l.withRulesetHook(conn, l.send),
+		internal.CtxHookAfterResolvedRuleset: l.withRulesetHook(conn, l.send),
+		internal.CtxHookAfterDial:            l.withDialedHook(conn, l.send),
+	})
+	inst := connector.NewStreamConnector(connCtx, conn, destAddr, srcAddr)
+	l.dispatcher.Dispatch(inst)
+}
+
+func (l *SocksListener) serveSocks4(connCtx context.Context, conn stdnet.Conn, header io.Reader) {
+	request, prErr := socks.ParseRequest4(header)
+	if prErr != nil {
+		_ = l.send4(conn, socks.RepServerFailure)
+		proxy.Logger(connCtx).Errorf("socks4: request: %s", prErr)
+		return
+	}
+	srcAddr := parseRemoteAddress(conn.RemoteAddr().String())
+
+	// Authenticate: SOCKS4 只有 USERID 字段，使用 "username:password" 形式传递凭证
+	if l.listenerOpts.Auth {
+		auth := proxy.Authentication{
+			Source:         srcAddr,
+			Authenticate:   proxy.AuthenticateBasic,
+			Authentication: string(request.UserID),
 		}
-		destAddr.Port = request.DstAddr.Port
-		if l.listenerOpts.Verbose {
-			proxy.Logger(connCtx).WithField("dest", destAddr).Infof("socks: connect")
+		principal, auErr := l.dispatcher.Authenticate(connCtx, auth)
+		if auErr != nil {
+			_ = l.send4(conn, socks.RepRuleFailure)
+			proxy.Logger(connCtx).Errorf("socks4: auth(user): %s", auErr)
+			return
 		}
+		connCtx = proxy.ContextWithPrincipal(connCtx, principal)
+	}
+
+	// Destination
+	if request.Command != socks.CommandConnect {
+		_ = l.send4(conn, socks.RepCommandNotSupported)
+		return
+	}
+	destAddr := parseDestAddress(net.NetworkTCP, request.DstAddr)
+	if l.listenerOpts.Verbose {
+		proxy.Logger(connCtx).WithField("dest", destAddr).Infof("socks4: connect")
+	}
 
-		// Dispatch
-		connCtx = internal.ContextWithHooks(connCtx, map[any]proxy.HookFunc{
-			internal.CtxHookAfterRuleset: l.withRulesetHook(tcpConn),
-			internal.CtxHookAfterDial:    l.withDialedHook(tcpConn),
-		})
-		inst := connector.NewStreamConnector(connCtx, tcpConn, destAddr, srcAddr)
-		l.dispatcher.Dispatch(inst)
+	// Dispatch
+	connCtx = internal.ContextWithHooks(connCtx, map[any]proxy.HookFunc{
+		internal.CtxHookAfterRuleset:         l.withRulesetHook(conn, l.send4),
+		internal.CtxHookAfterResolvedRuleset: l.withRulesetHook(conn, l.send4),
+		internal.CtxHookAfterDial:            l.withDialedHook(conn, l.send4),
 	})
+	inst := connector.NewStreamConnector(connCtx, conn, destAddr, srcAddr)
+	l.dispatcher.Dispatch(inst)
 }
 
-func (l *SocksListener) handshakeHeader(ctx context.Context, conn stdnet.Conn) error {
-	if request, err := socks.ParseMethodRequest(conn); err != nil {
+func (l *SocksListener) handshakeHeader(ctx context.Context, header io.Reader) error {
+	if request, err := socks.ParseMethodRequest(header); err != nil {
 		return fmt.Errorf("parse method request. %w", err)
 	} else if request.Ver != socks.VersionSocks5 {
 		return socks.ErrNotSupportVersion
@@ -122,29 +230,30 @@ func (l *SocksListener) handshakeSkipAuth(ctx context.Context, conn stdnet.Conn,
 	return err
 }
 
-func (l *SocksListener) handshakeUserAuth(ctx context.Context, conn stdnet.Conn, dispatcher proxy.Dispatcher) error {
+// handshakeUserAuth 完成用户名/密码认证，返回通过认证的用户名
+func (l *SocksListener) handshakeUserAuth(ctx context.Context, conn stdnet.Conn, dispatcher proxy.Dispatcher) (proxy.Principal, error) {
 	if _, err := conn.Write([]byte{socks.VersionSocks5, socks.MethodUserPassAuth}); err != nil {
-		return fmt.Errorf("send auth request. %w", err)
+		return proxy.Principal{}, fmt.Errorf("send auth request. %w", err)
 	}
 	request, upErr := socks.ParseUserPassRequest(conn)
 	if upErr != nil {
-		return fmt.Errorf("parse auth request. %w", upErr)
+		return proxy.Principal{}, fmt.Errorf("parse auth request. %w", upErr)
 	}
-	auErr := dispatcher.Authenticate(ctx, proxy.Authentication{
+	principal, auErr := dispatcher.Authenticate(ctx, proxy.Authentication{
 		Source:         parseRemoteAddress(conn.RemoteAddr().String()),
 		Authenticate:   proxy.AuthenticateBasic,
 		Authentication: string(request.User) + ":" + string(request.Pass),
 	})
 	if auErr != nil {
 		if _, err := conn.Write([]byte{socks.UserPassAuthVersion, socks.AuthFailure}); err != nil {
-			return fmt.Errorf("send failed auth reply. %w", err)
+			return proxy.Principal{}, fmt.Errorf("send failed auth reply. %w", err)
 		}
 	} else {
 		if _, err := conn.Write([]byte{socks.UserPassAuthVersion, socks.AuthSuccess}); err != nil {
-			return fmt.Errorf("send success auth reply. %w", err)
+			return proxy.Principal{}, fmt.Errorf("send success auth reply. %w", err)
 		}
 	}
-	return auErr
+	return principal, auErr
 }
 
 func (l *SocksListener) withAuthorizedHook(conn stdnet.Conn) proxy.HookFunc {
@@ -160,33 +269,60 @@ func (l *SocksListener) withAuthorizedHook(conn stdnet.Conn) proxy.HookFunc {
 	}
 }
 
-func (l *SocksListener) withRulesetHook(conn stdnet.Conn) proxy.HookFunc {
+func (l *SocksListener) withRulesetHook(conn stdnet.Conn, send socksSendFunc) proxy.HookFunc {
 	return func(ctx context.Context, state error, v ...any) error {
 		if state == nil || errors.Is(state, proxy.ErrNoRulesetMatched) {
 			return nil
 		}
-		return l.send(conn, socks.RepRuleFailure)
+		return send(conn, socks.RepRuleFailure)
 	}
 }
 
-func (l *SocksListener) withDialedHook(conn stdnet.Conn) proxy.HookFunc {
+func (l *SocksListener) withDialedHook(conn stdnet.Conn, send socksSendFunc) proxy.HookFunc {
 	return func(_ context.Context, state error, _ ...any) error {
 		if state == nil {
-			return l.send(conn, socks.RepSuccess)
+			return send(conn, socks.RepSuccess)
 		}
 		msg := state.Error()
-		if strings.Contains(msg, "connection refused") {
-			return l.send(conn, socks.RepConnectionRefused)
+		if errors.Is(state, dialer.ErrRejected) {
+			return send(conn, socks.RepRuleFailure)
+		} else if strings.Contains(msg, "connection refused") {
+			return send(conn, socks.RepConnectionRefused)
 		} else if strings.Contains(msg, "network is unreachable") {
-			return l.send(conn, socks.RepNetworkUnreachable)
+			return send(conn, socks.RepNetworkUnreachable)
 		} else {
-			return l.send(conn, socks.RepHostUnreachable)
+			return send(conn, socks.RepHostUnreachable)
+		}
+	}
+}
+
+// send4 发送 SOCKS4 响应，rep 为 SOCKS5 的响应状态码
+func (*SocksListener) send4(conn stdnet.Conn, rep uint8) error {
+	reply := socks.Reply4{
+		Version:  socks.Reply4Version,
+		Response: socks.Rep4Rejected,
+		BndAddr: socks.AddrSpec{
+			AddrType: socks.ATYPIPv4,
+			IP:       stdnet.IPv4zero,
+			Port:     0,
+		},
+	}
+	if rep == socks.RepSuccess {
+		reply.Response = socks.Rep4Granted
+		if tcpAddr, ok := conn.LocalAddr().(*stdnet.TCPAddr); ok && tcpAddr != nil && tcpAddr.IP.To4() != nil {
+			reply.BndAddr.IP = tcpAddr.IP
+			reply.BndAddr.Port = tcpAddr.Port
 		}
 	}
+	_, err := conn.Write(reply.Bytes())
+	return err
+}
+
+func (l *SocksListener) send(conn stdnet.Conn, rep uint8) error {
+	return l.sendWith(conn, rep, conn.LocalAddr())
 }
 
-func (*SocksListener) send(conn stdnet.Conn, rep uint8) error {
-	bindAddr := conn.LocalAddr()
+func (*SocksListener) sendWith(conn stdnet.Conn, rep uint8, bindAddr stdnet.Addr) error {
 	reply := socks.Reply{
 		Version:  socks.VersionSocks5,
 		Response: rep,
@@ -216,3 +352,14 @@ func (*SocksListener) send(conn stdnet.Conn, rep uint8) error {
 	_, err := conn.Write(reply.Bytes())
 	return err
 }
+
+func parseDestAddress(network net.Network, spec socks.AddrSpec) net.Address {
+	var destAddr net.Address
+	if spec.FQDN != "" {
+		destAddr = net.ParseDomainAddr(network, spec.FQDN)
+	} else {
+		destAddr = net.ParseIPAddr(network, spec.IP)
+	}
+	destAddr.Port = spec.Port
+	return destAddr
+}
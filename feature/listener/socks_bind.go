@@ -0,0 +1,117 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature"
+	"github.com/fluxproxy/fluxproxy/feature/connector"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/fluxproxy/fluxproxy/statute/socks"
+	stdnet "net"
+	"os"
+	"time"
+)
+
+const (
+	bindAcceptTimeout = time.Second * 60
+)
+
+// handleBind 处理 BIND 命令（RFC 1928）：打开监听端口并回复绑定地址，
+// 等待目标主机连入后再回复对端地址，随后将两端连接对接。
+func (l *SocksListener) handleBind(connCtx context.Context, tcpConn stdnet.Conn, request socks.Request, srcAddr net.Address) {
+	localAddr, _ := tcpConn.LocalAddr().(*stdnet.TCPAddr)
+	if localAddr == nil {
+		_ = l.send(tcpConn, socks.RepServerFailure)
+		proxy.Logger(connCtx).Errorf("socks: bind: unsupported local address: %s", tcpConn.LocalAddr())
+		return
+	}
+	listener, lErr := stdnet.ListenTCP("tcp", &stdnet.TCPAddr{IP: localAddr.IP})
+	if lErr != nil {
+		_ = l.send(tcpConn, socks.RepServerFailure)
+		proxy.Logger(connCtx).Errorf("socks: bind: listen tcp. %s", lErr)
+		return
+	}
+	defer helper.Close(listener)
+	// First reply: bound address
+	if err := l.sendWith(tcpConn, socks.RepSuccess, listener.Addr()); err != nil {
+		proxy.Logger(connCtx).Errorf("socks: bind: send first reply. %s", err)
+		return
+	}
+	if l.listenerOpts.Verbose {
+		proxy.Logger(connCtx).WithField("bind", listener.Addr()).Infof("socks: bind")
+	}
+
+	// Accept one incoming connection；控制连接关闭时停止等待
+	_ = listener.SetDeadline(time.Now().Add(bindAcceptTimeout))
+	acceptCtx, acceptCancel := context.WithCancel(connCtx)
+	defer acceptCancel()
+	go func() {
+		<-acceptCtx.Done()
+		_ = listener.Close()
+	}()
+	ctrlConn := newPeekConn(tcpConn)
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		// 客户端在第二次回复前不应发送数据，预读的数据仍保留在 ctrlConn 中
+		if _, err := ctrlConn.Peek(1); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			acceptCancel()
+		}
+	}()
+	peerConn, acErr := listener.AcceptTCP()
+	_ = tcpConn.SetReadDeadline(time.Now())
+	<-watchDone
+	_ = tcpConn.SetReadDeadline(time.Time{})
+	if acErr != nil && acceptCtx.Err() != nil {
+		proxy.Logger(connCtx).Warnf("socks: bind: control connection closed")
+		return
+	} else if acErr != nil {
+		_ = l.send(tcpConn, socks.RepTTLExpired)
+		proxy.Logger(connCtx).Errorf("socks: bind: accept. %s", acErr)
+		return
+	}
+	_ = listener.Close()
+	peerAddr := parseRemoteAddress(peerConn.RemoteAddr().String())
+
+	// 对端地址必须与请求中指定的地址一致（如指定）
+	if len(request.DstAddr.IP) > 0 && !request.DstAddr.IP.IsUnspecified() && !request.DstAddr.IP.Equal(peerAddr.IP) {
+		helper.Close(peerConn)
+		_ = l.send(tcpConn, socks.RepRuleFailure)
+		proxy.Logger(connCtx).Errorf("socks: bind: unexpected peer: %s", peerAddr)
+		return
+	}
+
+	// Ruleset
+	ruErr := feature.UseRuleset().Allow(connCtx, proxy.Permit{
+		Source:      srcAddr,
+		Destination: peerAddr,
+		Principal:   proxy.ContextPrincipal(connCtx),
+	})
+	if ruErr != nil && !errors.Is(ruErr, proxy.ErrNoRulesetMatched) {
+		helper.Close(peerConn)
+		_ = l.send(tcpConn, socks.RepRuleFailure)
+		proxy.Logger(connCtx).Errorf("socks: bind: ruleset: %s", ruErr)
+		return
+	}
+
+	// Second reply: peer address
+	if err := l.sendWith(tcpConn, socks.RepSuccess, peerConn.RemoteAddr()); err != nil {
+		helper.Close(peerConn)
+		proxy.Logger(connCtx).Errorf("socks: bind: send second reply. %s", err)
+		return
+	}
+	if l.listenerOpts.Verbose {
+		proxy.Logger(connCtx).WithField("peer", peerAddr).Infof("socks: bind: accepted")
+	}
+
+	// Connect
+	stream := connector.NewStreamConnector(connCtx, ctrlConn, peerAddr, srcAddr)
+	defer helper.Close(stream)
+	remote := proxy.NewDirectConnection(peerConn)
+	defer helper.Close(remote)
+	if err := stream.Connect(remote); err != nil && !helper.IsCopierError(err) && !errors.Is(err, context.Canceled) {
+		proxy.Logger(connCtx).Errorf("socks: bind: conn error: %s", err)
+	}
+}
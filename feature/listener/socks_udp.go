@@ -0,0 +1,141 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/connector"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/internal"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/fluxproxy/fluxproxy/statute/socks"
+	"io"
+	stdnet "net"
+	"sync"
+)
+
+// handleAssociate 处理 UDP ASSOCIATE 命令：为每个关联创建独立的 UDP 中继端口，
+// 中继端口的生命周期与控制连接（TCP）一致。
+func (l *SocksListener) handleAssociate(connCtx context.Context, tcpConn stdnet.Conn, request socks.Request, srcAddr net.Address) {
+	localAddr, _ := tcpConn.LocalAddr().(*stdnet.TCPAddr)
+	if localAddr == nil {
+		_ = l.send(tcpConn, socks.RepServerFailure)
+		proxy.Logger(connCtx).Errorf("socks: associate: unsupported local address: %s", tcpConn.LocalAddr())
+		return
+	}
+	relay, lErr := stdnet.ListenUDP("udp", &stdnet.UDPAddr{IP: localAddr.IP})
+	if lErr != nil {
+		_ = l.send(tcpConn, socks.RepServerFailure)
+		proxy.Logger(connCtx).Errorf("socks: associate: listen udp. %s", lErr)
+		return
+	}
+	defer helper.Close(relay)
+	if err := l.sendWith(tcpConn, socks.RepSuccess, relay.LocalAddr()); err != nil {
+		proxy.Logger(connCtx).Errorf("socks: associate: send reply. %s", err)
+		return
+	}
+	if l.listenerOpts.Verbose {
+		proxy.Logger(connCtx).WithField("relay", relay.LocalAddr()).Infof("socks: associate")
+	}
+
+	assocCtx, assocCancel := context.WithCancel(connCtx)
+	defer assocCancel()
+	// 控制连接关闭时，终止关联
+	go func() {
+		_, _ = io.Copy(io.Discard, tcpConn)
+		assocCancel()
+	}()
+	go func() {
+		<-assocCtx.Done()
+		_ = relay.Close()
+	}()
+
+	var (
+		flowsMu sync.Mutex
+		flows   = make(map[string]*connector.DatagramConnector)
+		client  *stdnet.UDPAddr
+	)
+	buf := make([]byte, 64*1024)
+	for {
+		n, from, rdErr := relay.ReadFromUDP(buf)
+		if rdErr != nil {
+			select {
+			case <-assocCtx.Done():
+			default:
+				proxy.Logger(connCtx).Errorf("socks: associate: read. %s", rdErr)
+			}
+			return
+		}
+		// 仅接受来自控制连接客户端的数据报
+		if !from.IP.Equal(srcAddr.IP) {
+			continue
+		}
+		if request.DstAddr.Port != 0 && from.Port != request.DstAddr.Port {
+			continue
+		}
+		if client == nil {
+			client = from
+		} else if client.Port != from.Port {
+			continue
+		}
+		datagram, pdErr := socks.ParseDatagram(buf[:n])
+		if pdErr != nil {
+			proxy.Logger(connCtx).Warnf("socks: associate: parse datagram. %s", pdErr)
+			continue
+		}
+		// 不支持分片
+		if datagram.Frag != 0 {
+			continue
+		}
+		data := make([]byte, len(datagram.Data))
+		copy(data, datagram.Data)
+
+		flowKey := datagram.DstAddr.String()
+		flowsMu.Lock()
+		flow, ok := flows[flowKey]
+		if !ok {
+			destSpec := datagram.DstAddr
+			destSpec.IP = append(stdnet.IP(nil), destSpec.IP...)
+			destAddr := parseDestAddress(net.NetworkUDP, destSpec)
+			if l.listenerOpts.Verbose {
+				proxy.Logger(connCtx).WithField("dest", destAddr).Infof("socks: associate: flow")
+			}
+			flowCtx := internal.ContextWithHooks(internal.SetupUdpContextLogger(assocCtx, client), map[any]proxy.HookFunc{
+				internal.CtxHookAfterRuleset:         l.withDatagramRulesetHook(connCtx, destAddr),
+				internal.CtxHookAfterResolvedRuleset: l.withDatagramRulesetHook(connCtx, destAddr),
+				internal.CtxHookAfterDial:            l.withDatagramDialedHook(connCtx, destAddr),
+			})
+			flow = connector.NewDatagramConnector(flowCtx, relay, client, destSpec, destAddr, srcAddr)
+			flows[flowKey] = flow
+			go func() {
+				l.dispatcher.Dispatch(flow)
+				flowsMu.Lock()
+				delete(flows, flowKey)
+				flowsMu.Unlock()
+			}()
+		}
+		flowsMu.Unlock()
+		flow.Deliver(data)
+	}
+}
+
+// withDatagramRulesetHook 数据报无法向客户端返回拒绝状态，在关联的日志中记录被拒绝的目标地址
+func (l *SocksListener) withDatagramRulesetHook(connCtx context.Context, destAddr net.Address) proxy.HookFunc {
+	return func(_ context.Context, state error, _ ...any) error {
+		if state == nil || errors.Is(state, proxy.ErrNoRulesetMatched) {
+			return nil
+		}
+		proxy.Logger(connCtx).WithField("dest", destAddr).Warnf("socks: associate: flow denied: %s", state)
+		return nil
+	}
+}
+
+func (l *SocksListener) withDatagramDialedHook(connCtx context.Context, destAddr net.Address) proxy.HookFunc {
+	return func(_ context.Context, state error, _ ...any) error {
+		if state == nil {
+			return nil
+		}
+		proxy.Logger(connCtx).WithField("dest", destAddr).Warnf("socks: associate: flow dial: %s", state)
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package listener
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tlsHandshakeTimeout = time.Second * 10
+)
+
+// TlsOptions 监听器的 TLS 参数
+type TlsOptions struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   uint16
+	ClientCAFile string
+}
+
+func (o TlsOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// tlsReloader 持有当前生效的 TLS 配置，证书文件变更时自动重新加载
+type tlsReloader struct {
+	opts    TlsOptions
+	current atomic.Pointer[tls.Config]
+}
+
+func newTlsConfig(runCtx context.Context, opts TlsOptions) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("tls: cert_file and key_file are required")
+	}
+	reloader := &tlsReloader{opts: opts}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	files := []string{opts.CertFile, opts.KeyFile}
+	if opts.ClientCAFile != "" {
+		files = append(files, opts.ClientCAFile)
+	}
+	if err := helper.WatchFiles(runCtx, files, reloader.reload); err != nil {
+		return nil, fmt.Errorf("tls: watch files. %w", err)
+	}
+	return &tls.Config{
+		MinVersion: reloader.opts.MinVersion,
+		NextProtos: []string{"http/1.1"},
+		// 旧版本 Go 仅以 Certificates/GetCertificate 判断是否配置了证书
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &reloader.current.Load().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return reloader.current.Load(), nil
+		},
+	}, nil
+}
+
+func (r *tlsReloader) reload() {
+	if err := r.load(); err != nil {
+		logrus.Errorf("tls: reload: %s", err)
+	} else {
+		logrus.Infof("tls: reload: %s", r.opts.CertFile)
+	}
+}
+
+func (r *tlsReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair. %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.opts.MinVersion,
+		// 代理协议依赖 HTTP/1.1 的 CONNECT 及连接劫持
+		NextProtos: []string{"http/1.1"},
+	}
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("tls: read client ca. %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificate in client ca: %s", r.opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.current.Store(config)
+	return nil
+}
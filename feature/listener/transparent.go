@@ -0,0 +1,100 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/connector"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/internal"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/sirupsen/logrus"
+	stdnet "net"
+	"strconv"
+)
+
+var (
+	_ proxy.Listener = (*TransparentListener)(nil)
+)
+
+const (
+	// TransparentModeRedirect iptables REDIRECT：通过 SO_ORIGINAL_DST 获取原始目标地址
+	TransparentModeRedirect = "redirect"
+	// TransparentModeTProxy iptables TPROXY：监听 Socket 设置 IP_TRANSPARENT，本地地址即原始目标地址
+	TransparentModeTProxy = "tproxy"
+)
+
+type TransparentOptions struct {
+	Mode string
+}
+
+// TransparentListener 透明代理监听器，接收由 iptables 重定向的 TCP 连接
+type TransparentListener struct {
+	opts         TransparentOptions
+	listenerOpts proxy.ListenerOptions
+	dispatcher   proxy.Dispatcher
+}
+
+func NewTransparentListener(
+	listenerOpts proxy.ListenerOptions,
+	transparentOpts TransparentOptions,
+	dispatcher proxy.Dispatcher,
+) *TransparentListener {
+	return &TransparentListener{
+		listenerOpts: listenerOpts,
+		opts:         transparentOpts,
+		dispatcher:   dispatcher,
+	}
+}
+
+func (l *TransparentListener) Init(runCtx context.Context) error {
+	if l.listenerOpts.Port <= 0 {
+		return fmt.Errorf("transparent: invalid port: %d", l.listenerOpts.Port)
+	}
+	switch l.opts.Mode {
+	case TransparentModeRedirect, TransparentModeTProxy:
+		return nil
+	default:
+		return fmt.Errorf("transparent: invalid mode: %s", l.opts.Mode)
+	}
+}
+
+func (l *TransparentListener) Listen(serveCtx context.Context) error {
+	addr := stdnet.JoinHostPort(l.listenerOpts.Address, strconv.Itoa(l.listenerOpts.Port))
+	logrus.Infof("transparent: listen(%s): %s", l.opts.Mode, addr)
+	listener, lErr := listenTransparent(serveCtx, addr, l.opts.Mode)
+	if lErr != nil {
+		return fmt.Errorf("listen %s. %w", addr, lErr)
+	}
+	return tcpServeWith(serveCtx, listener, func(tcpConn *stdnet.TCPConn) {
+		connCtx := internal.SetupTcpContextLogger(serveCtx, tcpConn)
+		var dest *stdnet.TCPAddr
+		if l.opts.Mode == TransparentModeTProxy {
+			dest, _ = tcpConn.LocalAddr().(*stdnet.TCPAddr)
+		} else {
+			var odErr error
+			if dest, odErr = originalDestination(tcpConn); odErr != nil {
+				helper.Close(tcpConn)
+				proxy.Logger(connCtx).Errorf("transparent: original destination: %s", odErr)
+				return
+			}
+		}
+		if dest == nil {
+			helper.Close(tcpConn)
+			proxy.Logger(connCtx).Errorf("transparent: unsupported local address: %s", tcpConn.LocalAddr())
+			return
+		}
+		srcAddr := parseRemoteAddress(tcpConn.RemoteAddr().String())
+
+		// Destination
+		destAddr := net.ParseIPAddr(net.NetworkTCP, dest.IP)
+		destAddr.Port = dest.Port
+		if l.listenerOpts.Verbose {
+			proxy.Logger(connCtx).WithField("dest", destAddr).Infof("transparent: connect")
+		}
+
+		// Dispatch
+		inst := connector.NewStreamConnector(connCtx, tcpConn, destAddr, srcAddr)
+		l.dispatcher.Dispatch(inst)
+	})
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package listener
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	stdnet "net"
+	"syscall"
+)
+
+const (
+	// linux/netfilter_ipv4.h, linux/netfilter_ipv6/ip6_tables.h
+	soOriginalDst     = 80
+	ip6tSoOriginalDst = 80
+	// linux/in6.h
+	ipv6Transparent = 75
+)
+
+func listenTransparent(ctx context.Context, addr string, mode string) (*stdnet.TCPListener, error) {
+	lc := stdnet.ListenConfig{}
+	if mode == TransparentModeTProxy {
+		lc.Control = func(network, address string, rc syscall.RawConn) error {
+			var opErr error
+			if err := rc.Control(func(fd uintptr) {
+				if opErr = syscall.SetsockoptInt(int(fd), syscall.SOL_IP, syscall.IP_TRANSPARENT, 1); opErr != nil {
+					return
+				}
+				if network == "tcp6" || network == "tcp" {
+					// 双栈 Socket 同时需要 IPv6 的透明选项；纯 IPv4 Socket 上设置失败可忽略
+					_ = syscall.SetsockoptInt(int(fd), syscall.SOL_IPV6, ipv6Transparent, 1)
+				}
+			}); err != nil {
+				return err
+			}
+			if opErr != nil {
+				return fmt.Errorf("set IP_TRANSPARENT. %w", opErr)
+			}
+			return nil
+		}
+	}
+	listener, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return listener.(*stdnet.TCPListener), nil
+}
+
+func originalDestination(conn *stdnet.TCPConn) (*stdnet.TCPAddr, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	localAddr, _ := conn.LocalAddr().(*stdnet.TCPAddr)
+	if localAddr == nil {
+		return nil, errors.New("not tcp address")
+	}
+	var dest *stdnet.TCPAddr
+	var opErr error
+	ctlErr := rawConn.Control(func(fd uintptr) {
+		if localAddr.IP.To4() != nil {
+			// struct sockaddr_in: family(2) port(2) addr(4) zero(8)
+			mreq, err := syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+			if err != nil {
+				opErr = err
+				return
+			}
+			raw := mreq.Multiaddr
+			dest = &stdnet.TCPAddr{
+				IP:   stdnet.IPv4(raw[4], raw[5], raw[6], raw[7]),
+				Port: int(binary.BigEndian.Uint16(raw[2:4])),
+			}
+		} else {
+			// struct sockaddr_in6
+			info, err := syscall.GetsockoptIPv6MTUInfo(int(fd), syscall.IPPROTO_IPV6, ip6tSoOriginalDst)
+			if err != nil {
+				opErr = err
+				return
+			}
+			port := make([]byte, 2)
+			binary.NativeEndian.PutUint16(port, info.Addr.Port)
+			dest = &stdnet.TCPAddr{
+				IP:   append(stdnet.IP(nil), info.Addr.Addr[:]...),
+				Port: int(binary.BigEndian.Uint16(port)),
+			}
+		}
+	})
+	if ctlErr != nil {
+		return nil, ctlErr
+	}
+	if opErr != nil {
+		return nil, fmt.Errorf("getsockopt SO_ORIGINAL_DST. %w", opErr)
+	}
+	return dest, nil
+}
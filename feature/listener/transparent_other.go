@@ -0,0 +1,21 @@
+//go:build !linux
+
+package listener
+
+import (
+	"context"
+	"errors"
+	stdnet "net"
+)
+
+var (
+	errTransparentNotSupported = errors.New("transparent proxy is only supported on linux")
+)
+
+func listenTransparent(ctx context.Context, addr string, mode string) (*stdnet.TCPListener, error) {
+	return nil, errTransparentNotSupported
+}
+
+func originalDestination(conn *stdnet.TCPConn) (*stdnet.TCPAddr, error) {
+	return nil, errTransparentNotSupported
+}
@@ -2,13 +2,21 @@ package feature
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/bytepowered/assert"
 	"github.com/bytepowered/cache"
 	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/dialer"
+	"github.com/fluxproxy/fluxproxy/feature/resolver"
+	"github.com/fluxproxy/fluxproxy/internal"
 	"github.com/fluxproxy/fluxproxy/net"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
 	stdnet "net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +24,9 @@ var (
 	_ proxy.Resolver = (*CacheResolver)(nil)
 )
 
+// staleAnswerTTL 返回过期记录时使用的 TTL（RFC 8767）
+const staleAnswerTTL = 30 * time.Second
+
 var (
 	resolverOnce = sync.Once{}
 	resolverInst *CacheResolver
@@ -23,62 +34,361 @@ var (
 
 type Options struct {
 	CacheSize int
-	CacheTTL  time.Duration
-	Hosts     map[string]string
+	// CacheTTL 没有 TTL 的记录（系统解析、hosts、IP 地址）的缓存时长
+	CacheTTL time.Duration
+	// MinTTL, MaxTTL 限定上游 DNS 记录 TTL 的范围
+	MinTTL time.Duration
+	MaxTTL time.Duration
+	// NegativeTTL 域名不存在、无地址记录及服务器失败（SERVFAIL）结果的缓存时长；为 0 时不缓存
+	NegativeTTL time.Duration
+	// StaleTTL 记录过期后仍可返回旧结果的时长，期间在后台刷新；为 0 时不返回过期记录
+	StaleTTL time.Duration
+	// PrefetchHits 命中次数达到此值的记录，在过期前（剩余不足 TTL 的 1/10）提前刷新；为 0 时不预取
+	PrefetchHits int
+	Hosts        map[string]string
+	// Upstream 上游 DNS 服务器；未配置时使用系统解析
+	Upstream *resolver.Upstream
+	// Policies 按域名后缀指定的上游 DNS 服务器，优先于 Upstream
+	Policies []resolver.Policy
+	// ViaOutbound 经由连接选定的出站代理查询上游 DNS 服务器
+	ViaOutbound bool
+	// IPStrategy 地址选择策略：ipv4-only, ipv6-only, prefer-ipv4, prefer-ipv6
+	IPStrategy string
 }
 
 type CacheResolver struct {
-	cached cache.Cache
+	cached       cache.Cache
+	defaultTTL   time.Duration
+	minTTL       time.Duration
+	maxTTL       time.Duration
+	negativeTTL  time.Duration
+	staleTTL     time.Duration
+	prefetchHits int64
+	upstream     *resolver.Upstream
+	policies     []resolver.Policy
+	viaOutbound  bool
+	ipStrategy   string
+	clock        cache.Clock
 }
 
-func (d *CacheResolver) Resolve(ctx context.Context, addr net.Address) (stdnet.IP, error) {
-	configer := proxy.Configer(ctx)
-	name := addr.Addr()
-	ipv, err := d.cached.GetOrLoad(name, func(_ interface{}) (cache.Expirable, error) {
-		// S1: 通过配置文件实现 resolve/rewrite
-		if userIP := configer.String("resolver.hosts." + name); userIP != "" {
-			rAddr, err := net.ParseAddress(net.NetworkTCP, userIP+":80")
-			if err != nil {
-				logrus.Warnf("resolver.hosts.%s=%s is not ip address", name, userIP)
-			} else if rAddr.IsIP() {
-				return cache.NewDefault(rAddr.IP), nil
-			} else {
-				logrus.Warnf("resolver.hosts.%s=%s is not ip address", name, userIP)
-			}
-		}
-		// S2: IP地址，直接返回
-		if addr.IsIP() {
-			return cache.NewDefault(addr.IP), nil
+// cacheEntry 缓存的解析结果；err 不为空时为否定缓存。static 为通过 Set 写入的静态记录，不过期、不刷新。
+type cacheEntry struct {
+	ips        []stdnet.IP
+	err        error
+	ttl        time.Duration
+	expireAt   time.Time
+	static     bool
+	hits       atomic.Int64
+	refreshing atomic.Bool
+}
+
+func newCacheEntry(ips []stdnet.IP, err error, ttl time.Duration, now time.Time) *cacheEntry {
+	return &cacheEntry{ips: ips, err: err, ttl: ttl, expireAt: now.Add(ttl)}
+}
+
+// Resolve 返回域名解析得到的全部地址，按地址选择策略过滤并排序
+func (d *CacheResolver) Resolve(ctx context.Context, addr net.Address) ([]stdnet.IP, error) {
+	entry, err := d.get(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return entry.ips, entry.err
+}
+
+// Lookup 与 Resolve 相同，同时返回缓存记录的剩余有效时长；返回过期记录时为 staleAnswerTTL
+func (d *CacheResolver) Lookup(ctx context.Context, addr net.Address) ([]stdnet.IP, time.Duration, error) {
+	entry, err := d.get(ctx, addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entry.static {
+		return entry.ips, d.defaultTTL, entry.err
+	}
+	ttl := entry.expireAt.Sub(d.clock.Now())
+	if ttl <= 0 {
+		ttl = staleAnswerTTL
+	}
+	return entry.ips, ttl, entry.err
+}
+
+// LookupCNAME 查询域名的 CNAME 记录，不使用缓存；没有别名时返回空字符串
+func (d *CacheResolver) LookupCNAME(ctx context.Context, name string) (string, time.Duration, error) {
+	if upstream := d.selectUpstream(name); upstream != nil {
+		answer, err := upstream.Lookup(ctx, name, dnsmessage.TypeCNAME)
+		if err != nil {
+			return "", 0, fmt.Errorf("resolve %s. %w", name, err)
 		}
-		// S3: 尝试解析域名
-		addr, err := stdnet.ResolveIPAddr("ip", name)
+		return answer.CNAME, d.clampTTL(answer.TTL), nil
+	}
+	cname, err := stdnet.DefaultResolver.LookupCNAME(ctx, name)
+	if err != nil {
+		return "", 0, fmt.Errorf("resolve %s. %w", name, err)
+	}
+	if cname = strings.TrimSuffix(cname, "."); strings.EqualFold(cname, name) {
+		return "", d.defaultTTL, nil
+	}
+	return cname, d.defaultTTL, nil
+}
+
+func (d *CacheResolver) get(ctx context.Context, addr net.Address) (*cacheEntry, error) {
+	key := addr.Addr()
+	if outbound, ok := d.lookupOutbound(ctx); ok {
+		ctx = resolver.ContextWithDialer(ctx, outbound.Name(), dialWith(outbound))
+		key = key + "@" + outbound.Name()
+	}
+	v, err := d.cached.GetOrLoad(key, func(_ interface{}) (cache.Expirable, error) {
+		entry, err := d.load(ctx, addr)
 		if err != nil {
 			return cache.Expirable{Value: nil}, err
 		}
-		return cache.NewDefault(addr.IP), err
+		return cache.NewExpirable(entry, d.retention(entry)), nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	return ipv.(stdnet.IP), nil
+	entry := v.(*cacheEntry)
+	if d.shouldRefresh(entry, d.clock.Now()) && entry.refreshing.CompareAndSwap(false, true) {
+		go d.refresh(ctx, key, addr, entry)
+	}
+	return entry, nil
 }
 
+// load 解析并生成缓存条目；确定的失败结果生成否定缓存条目，其它错误不缓存
+func (d *CacheResolver) load(ctx context.Context, addr net.Address) (*cacheEntry, error) {
+	ips, ttl, err := d.lookup(ctx, addr)
+	if err == nil {
+		if len(ips) > 0 {
+			return newCacheEntry(ips, nil, ttl, d.clock.Now()), nil
+		}
+		err = fmt.Errorf("resolve %s. %w: %s", addr.Addr(), resolver.ErrNoAddress, d.ipStrategy)
+	} else if !isNegativeError(err) {
+		return nil, err
+	}
+	if d.negativeTTL <= 0 {
+		return nil, err
+	}
+	return newCacheEntry(nil, err, d.negativeTTL, d.clock.Now()), nil
+}
+
+func (d *CacheResolver) lookup(ctx context.Context, addr net.Address) ([]stdnet.IP, time.Duration, error) {
+	name := addr.Addr()
+	// S1: 通过配置文件实现 resolve/rewrite
+	if userIP := proxy.Configer(ctx).String("resolver.hosts." + name); userIP != "" {
+		rAddr, err := net.ParseAddress(net.NetworkTCP, userIP+":80")
+		if err != nil {
+			logrus.Warnf("resolver.hosts.%s=%s is not ip address", name, userIP)
+		} else if rAddr.IsIP() {
+			return []stdnet.IP{rAddr.IP}, d.defaultTTL, nil
+		} else {
+			logrus.Warnf("resolver.hosts.%s=%s is not ip address", name, userIP)
+		}
+	}
+	// S2: IP地址，直接返回
+	if addr.IsIP() {
+		return []stdnet.IP{addr.IP}, d.defaultTTL, nil
+	}
+	// S3: 尝试解析域名
+	if upstream := d.selectUpstream(name); upstream != nil {
+		ips, ttl, err := d.lookupUpstream(ctx, upstream, name)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resolver.SortIPs(ips, d.ipStrategy), d.clampTTL(ttl), nil
+	}
+	ips, err := d.lookupSystem(ctx, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resolver.SortIPs(ips, d.ipStrategy), d.defaultTTL, nil
+}
+
+// retention 缓存条目的保留时长；肯定结果额外保留可返回过期记录的时长
+func (d *CacheResolver) retention(entry *cacheEntry) time.Duration {
+	if entry.err != nil {
+		return entry.ttl
+	}
+	return entry.ttl + d.staleTTL
+}
+
+// shouldRefresh 记录命中，返回是否需要在后台刷新：已过期（返回旧结果）或热门记录即将过期
+func (d *CacheResolver) shouldRefresh(entry *cacheEntry, now time.Time) bool {
+	hits := entry.hits.Add(1)
+	if entry.err != nil || entry.static {
+		return false
+	}
+	remain := entry.expireAt.Sub(now)
+	if remain <= 0 {
+		return true
+	}
+	return d.prefetchHits > 0 && hits >= d.prefetchHits && remain <= entry.ttl/10
+}
+
+// refresh 在后台重新解析并替换缓存条目；失败（包括服务器失败）时保留原条目，由后续查询再次刷新
+func (d *CacheResolver) refresh(ctx context.Context, key string, addr net.Address, entry *cacheEntry) {
+	next, err := d.load(context.WithoutCancel(ctx), addr)
+	if err == nil && errors.Is(next.err, resolver.ErrServerFailure) {
+		err = next.err
+	}
+	if err != nil {
+		entry.refreshing.Store(false)
+		logrus.Warnf("resolver: refresh %s. %s", key, err)
+		return
+	}
+	_ = d.cached.SetWithExpire(key, next, d.retention(next))
+}
+
+func (d *CacheResolver) clampTTL(ttl uint32) time.Duration {
+	v := time.Duration(ttl) * time.Second
+	if v < d.minTTL {
+		return d.minTTL
+	}
+	if d.maxTTL > 0 && v > d.maxTTL {
+		return d.maxTTL
+	}
+	return v
+}
+
+// isNegativeError 返回是否为可缓存的确定失败结果
+func isNegativeError(err error) bool {
+	if errors.Is(err, resolver.ErrNotFound) || errors.Is(err, resolver.ErrServerFailure) {
+		return true
+	}
+	var dnsErr *stdnet.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// selectUpstream 按域名后缀选择上游 DNS 服务器，均未匹配时使用默认的上游服务器
+func (d *CacheResolver) selectUpstream(name string) *resolver.Upstream {
+	for _, policy := range d.policies {
+		if policy.Match(name) {
+			return policy.Upstream()
+		}
+	}
+	return d.upstream
+}
+
+// ViaOutbound 返回是否经由出站代理查询上游 DNS 服务器
+func (d *CacheResolver) ViaOutbound() bool {
+	return d.viaOutbound
+}
+
+// lookupOutbound 返回经由查询的出站代理；直连及拒绝的连接在本地查询
+func (d *CacheResolver) lookupOutbound(ctx context.Context) (proxy.Dialer, bool) {
+	if !d.viaOutbound {
+		return nil, false
+	}
+	outbound, ok := ctx.Value(internal.CtxKeyOutbound).(proxy.Dialer)
+	if !ok || outbound.Name() == dialer.DIRECT || outbound.Name() == dialer.REJECT {
+		return nil, false
+	}
+	return outbound, true
+}
+
+// lookupUpstream 按地址选择策略同时查询 A 与 AAAA 记录，任一查询成功即返回；TTL 取有地址的应答中的最小值
+func (d *CacheResolver) lookupUpstream(ctx context.Context, upstream *resolver.Upstream, name string) ([]stdnet.IP, uint32, error) {
+	qtypes := make([]dnsmessage.Type, 0, 2)
+	if resolver.WantIPv4(d.ipStrategy) {
+		qtypes = append(qtypes, dnsmessage.TypeA)
+	}
+	if resolver.WantIPv6(d.ipStrategy) {
+		qtypes = append(qtypes, dnsmessage.TypeAAAA)
+	}
+	answers := make([]resolver.Answer, len(qtypes))
+	errs := make([]error, len(qtypes))
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func(i int, qtype dnsmessage.Type) {
+			defer wg.Done()
+			answers[i], errs[i] = upstream.Lookup(ctx, name, qtype)
+		}(i, qtype)
+	}
+	wg.Wait()
+	var ips []stdnet.IP
+	var ttl uint32
+	var lastErr error
+	for i := range qtypes {
+		if errs[i] != nil {
+			lastErr = errs[i]
+			continue
+		}
+		if len(answers[i].IPs) == 0 {
+			continue
+		}
+		if len(ips) == 0 || answers[i].TTL < ttl {
+			ttl = answers[i].TTL
+		}
+		ips = append(ips, answers[i].IPs...)
+	}
+	if len(ips) == 0 && lastErr != nil {
+		return nil, 0, fmt.Errorf("resolve %s. %w", name, lastErr)
+	}
+	return ips, ttl, nil
+}
+
+func (d *CacheResolver) lookupSystem(ctx context.Context, name string) ([]stdnet.IP, error) {
+	network := "ip"
+	switch d.ipStrategy {
+	case resolver.IPStrategyIPv4Only:
+		network = "ip4"
+	case resolver.IPStrategyIPv6Only:
+		network = "ip6"
+	}
+	ips, err := stdnet.DefaultResolver.LookupIP(ctx, network, name)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s. %w", name, err)
+	}
+	return ips, nil
+}
+
+// dialWith 经由出站 Dialer 连接上游 DNS 服务器
+func dialWith(outbound proxy.Dialer) resolver.DialFunc {
+	return func(ctx context.Context, network, address string) (stdnet.Conn, error) {
+		remote, err := net.ParseAddress(net.NetworkTCP, address)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := outbound.Dial(ctx, remote)
+		if err != nil {
+			return nil, err
+		}
+		return conn.Conn(), nil
+	}
+}
+
+// Set 设置固定的解析结果，不过期
 func (d *CacheResolver) Set(name string, ip stdnet.IP) {
-	_ = d.cached.Set(name, ip)
+	_ = d.cached.Set(name, &cacheEntry{ips: []stdnet.IP{ip}, static: true})
 }
 
 func InitResolverWith(opts Options) *CacheResolver {
 	resolverOnce.Do(func() {
-		resolverInst = &CacheResolver{
-			cached: cache.New(opts.CacheSize).
-				LRU().
-				Expiration(opts.CacheTTL).
-				Build(),
-		}
+		resolverInst = newCacheResolver(opts, cache.NewRealClock())
 	})
 	return resolverInst
 }
 
+func newCacheResolver(opts Options, clock cache.Clock) *CacheResolver {
+	return &CacheResolver{
+		defaultTTL:   opts.CacheTTL,
+		minTTL:       opts.MinTTL,
+		maxTTL:       opts.MaxTTL,
+		negativeTTL:  opts.NegativeTTL,
+		staleTTL:     opts.StaleTTL,
+		prefetchHits: int64(opts.PrefetchHits),
+		upstream:     opts.Upstream,
+		policies:     opts.Policies,
+		viaOutbound:  opts.ViaOutbound,
+		ipStrategy:   opts.IPStrategy,
+		clock:        clock,
+		// 缓存条目按各自的 TTL 过期；Set 写入的静态记录不过期
+		cached: cache.New(opts.CacheSize).
+			LRU().
+			Clock(clock).
+			Build(),
+	}
+}
+
 func UseResolver() *CacheResolver {
 	assert.MustNotNil(resolverInst, "resolver not initialized")
 	return resolverInst
@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"fmt"
+	stdnet "net"
+	"strings"
+)
+
+// ip strategy
+const (
+	IPStrategyPreferIPv4 = "prefer-ipv4"
+	IPStrategyPreferIPv6 = "prefer-ipv6"
+	IPStrategyIPv4Only   = "ipv4-only"
+	IPStrategyIPv6Only   = "ipv6-only"
+)
+
+// ParseIPStrategy 解析地址选择策略，默认为 prefer-ipv4
+func ParseIPStrategy(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return IPStrategyPreferIPv4, nil
+	case IPStrategyPreferIPv4, IPStrategyPreferIPv6, IPStrategyIPv4Only, IPStrategyIPv6Only:
+		return strings.ToLower(s), nil
+	default:
+		return "", fmt.Errorf("invalid ip strategy: %s", s)
+	}
+}
+
+// WantIPv4 返回策略是否需要 IPv4 地址
+func WantIPv4(strategy string) bool {
+	return strategy != IPStrategyIPv6Only
+}
+
+// WantIPv6 返回策略是否需要 IPv6 地址
+func WantIPv6(strategy string) bool {
+	return strategy != IPStrategyIPv4Only
+}
+
+// SortIPs 按策略过滤并排序地址，同一地址族内保持原有顺序
+func SortIPs(ips []stdnet.IP, strategy string) []stdnet.IP {
+	v4 := make([]stdnet.IP, 0, len(ips))
+	v6 := make([]stdnet.IP, 0, len(ips))
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch strategy {
+	case IPStrategyIPv4Only:
+		return v4
+	case IPStrategyIPv6Only:
+		return v6
+	case IPStrategyPreferIPv6:
+		return append(v6, v4...)
+	default:
+		return append(v4, v6...)
+	}
+}
@@ -0,0 +1,269 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nameserver scheme
+const (
+	SchemeUDP   = "udp"
+	SchemeTCP   = "tcp"
+	SchemeTLS   = "tls"
+	SchemeHTTPS = "https"
+)
+
+const (
+	maxMessageSize   = 65535
+	dnsMessageMime   = "application/dns-message"
+	defaultDNSPort   = "53"
+	defaultDoTPort   = "853"
+	flagTruncatedBit = 0x02
+)
+
+// Nameserver 上游 DNS 服务器，完成一次 DNS 报文的请求与应答
+type Nameserver interface {
+	Exchange(ctx context.Context, query []byte) ([]byte, error)
+	String() string
+}
+
+// DialFunc 建立到上游 DNS 服务器的连接
+type DialFunc func(ctx context.Context, network, address string) (stdnet.Conn, error)
+
+type dialerCtxKey struct{}
+
+type contextDialer struct {
+	name string
+	dial DialFunc
+}
+
+// ContextWithDialer 指定本次查询连接上游 DNS 服务器的方式，例如经由出站代理连接；
+// 由于出站代理仅支持 TCP 连接，UDP 服务器改用 TCP 查询
+func ContextWithDialer(ctx context.Context, name string, dial DialFunc) context.Context {
+	return context.WithValue(ctx, dialerCtxKey{}, contextDialer{name: name, dial: dial})
+}
+
+func dialerFromContext(ctx context.Context) (contextDialer, bool) {
+	v, ok := ctx.Value(dialerCtxKey{}).(contextDialer)
+	return v, ok
+}
+
+// ParseNameserver 解析上游 DNS 服务器地址，格式：
+//
+//	1.1.1.1 / udp://1.1.1.1:53          UDP，应答被截断时使用 TCP 重试
+//	tcp://1.1.1.1:53                    TCP
+//	tls://1.1.1.1:853                   DNS-over-TLS，以主机名校验服务端证书
+//	https://1.1.1.1/dns-query           DNS-over-HTTPS
+func ParseNameserver(s string, dial DialFunc) (Nameserver, error) {
+	if !strings.Contains(s, "://") {
+		s = SchemeUDP + "://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nameserver: %s. %w", s, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid nameserver: %s: host is required", s)
+	}
+	if dial == nil {
+		dial = (&stdnet.Dialer{}).DialContext
+	}
+	switch strings.ToLower(u.Scheme) {
+	case SchemeUDP:
+		return &udpNameserver{address: hostPort(u, defaultDNSPort), dial: dial}, nil
+	case SchemeTCP:
+		return &streamNameserver{address: hostPort(u, defaultDNSPort), dial: dial}, nil
+	case SchemeTLS:
+		return &streamNameserver{address: hostPort(u, defaultDoTPort), dial: dial,
+			tls: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}, nil
+	case SchemeHTTPS:
+		return newHttpsNameserver(u, dial), nil
+	default:
+		return nil, fmt.Errorf("invalid nameserver: %s: unsupported scheme: %s", s, u.Scheme)
+	}
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return stdnet.JoinHostPort(u.Hostname(), port)
+}
+
+func setDeadline(ctx context.Context, conn stdnet.Conn) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+}
+
+////
+
+type udpNameserver struct {
+	address string
+	dial    DialFunc
+}
+
+func (n *udpNameserver) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if _, ok := dialerFromContext(ctx); ok {
+		tcp := &streamNameserver{address: n.address, dial: n.dial}
+		return tcp.Exchange(ctx, query)
+	}
+	conn, err := n.dial(ctx, "udp", n.address)
+	if err != nil {
+		return nil, fmt.Errorf("dial. %w", err)
+	}
+	defer helper.Close(conn)
+	setDeadline(ctx, conn)
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("write. %w", err)
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		size, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("read. %w", err)
+		}
+		// 忽略 ID 不匹配的应答
+		if size < 12 || !bytes.Equal(buf[:2], query[:2]) {
+			continue
+		}
+		if buf[2]&flagTruncatedBit != 0 {
+			tcp := &streamNameserver{address: n.address, dial: n.dial}
+			return tcp.Exchange(ctx, query)
+		}
+		return buf[:size], nil
+	}
+}
+
+func (n *udpNameserver) String() string {
+	return SchemeUDP + "://" + n.address
+}
+
+////
+
+// streamNameserver TCP 及 DNS-over-TLS，报文以 2 字节长度为前缀
+type streamNameserver struct {
+	address string
+	dial    DialFunc
+	tls     *tls.Config
+}
+
+func (n *streamNameserver) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	dial := n.dial
+	if v, ok := dialerFromContext(ctx); ok {
+		dial = v.dial
+	}
+	conn, err := dial(ctx, "tcp", n.address)
+	if err != nil {
+		return nil, fmt.Errorf("dial. %w", err)
+	}
+	defer helper.Close(conn)
+	setDeadline(ctx, conn)
+	if n.tls != nil {
+		tlsConn := tls.Client(conn, n.tls)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("tls handshake. %w", err)
+		}
+		conn = tlsConn
+	}
+	packet := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(packet, uint16(len(query)))
+	copy(packet[2:], query)
+	if _, err := conn.Write(packet); err != nil {
+		return nil, fmt.Errorf("write. %w", err)
+	}
+	if _, err := io.ReadFull(conn, packet[:2]); err != nil {
+		return nil, fmt.Errorf("read length. %w", err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(packet[:2]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, fmt.Errorf("read. %w", err)
+	}
+	return resp, nil
+}
+
+func (n *streamNameserver) String() string {
+	if n.tls != nil {
+		return SchemeTLS + "://" + n.address
+	}
+	return SchemeTCP + "://" + n.address
+}
+
+////
+
+// httpsNameserver DNS-over-HTTPS (RFC 8484)，使用 POST 方式发送报文
+type httpsNameserver struct {
+	url    string
+	client *http.Client
+	// 经由其它 Dialer 查询时使用的 Client，避免复用不同方式建立的连接
+	clients sync.Map
+}
+
+func newHttpsNameserver(u *url.URL, dial DialFunc) *httpsNameserver {
+	return &httpsNameserver{
+		url:    u.String(),
+		client: newHttpsClient(dial),
+	}
+}
+
+func newHttpsClient(dial DialFunc) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dial,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+			MaxIdleConnsPerHost: 4,
+		},
+	}
+}
+
+func (n *httpsNameserver) clientFor(ctx context.Context) *http.Client {
+	v, ok := dialerFromContext(ctx)
+	if !ok {
+		return n.client
+	}
+	if client, ok := n.clients.Load(v.name); ok {
+		return client.(*http.Client)
+	}
+	client, _ := n.clients.LoadOrStore(v.name, newHttpsClient(v.dial))
+	return client.(*http.Client)
+}
+
+func (n *httpsNameserver) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("new request. %w", err)
+	}
+	req.Header.Set("Content-Type", dnsMessageMime)
+	req.Header.Set("Accept", dnsMessageMime)
+	resp, err := n.clientFor(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request. %w", err)
+	}
+	defer helper.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("response status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return nil, fmt.Errorf("read. %w", err)
+	}
+	return body, nil
+}
+
+func (n *httpsNameserver) String() string {
+	return n.url
+}
@@ -0,0 +1,27 @@
+package resolver
+
+import (
+	"github.com/fluxproxy/fluxproxy/net"
+)
+
+// Policy 按域名后缀指定上游 DNS 服务器，匹配域名自身及其全部子域名
+type Policy struct {
+	suffixes *net.DomainTrie
+	upstream *Upstream
+}
+
+func NewPolicy(suffixes []string, upstream *Upstream) Policy {
+	trie := net.NewDomainTrie()
+	for _, suffix := range suffixes {
+		trie.Insert(suffix)
+	}
+	return Policy{suffixes: trie, upstream: upstream}
+}
+
+func (p Policy) Match(name string) bool {
+	return p.suffixes.Match(name)
+}
+
+func (p Policy) Upstream() *Upstream {
+	return p.upstream
+}
@@ -0,0 +1,223 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"math/rand/v2"
+	stdnet "net"
+	"strings"
+	"time"
+)
+
+// query strategy
+const (
+	StrategyParallel   = "parallel"
+	StrategySequential = "sequential"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+	ednsUDPSize    = 1232
+)
+
+var (
+	// ErrNotFound 域名不存在（NXDOMAIN），为确定的结果，不再查询其它服务器
+	ErrNotFound = errors.New("resolver: no such host")
+	// ErrServerFailure 服务器无法完成查询（SERVFAIL），继续查询其它服务器
+	ErrServerFailure = errors.New("resolver: server failure")
+	// ErrNoAddress 域名存在，但没有所需地址族的地址记录
+	ErrNoAddress = errors.New("resolver: no address")
+)
+
+// Answer 查询得到的地址记录
+type Answer struct {
+	IPs   []stdnet.IP
+	CNAME string // 应答中首个 CNAME 记录的目标域名
+	TTL   uint32 // 应答记录中的最小 TTL
+}
+
+// Upstream 查询上游 DNS 服务器；parallel 并行查询全部服务器，使用最先返回的结果；
+// sequential 按顺序查询，失败时尝试下一个服务器。每个服务器的查询超时相互独立。
+type Upstream struct {
+	servers  []Nameserver
+	parallel bool
+	timeout  time.Duration
+}
+
+func NewUpstream(servers []Nameserver, strategy string, timeout time.Duration) (*Upstream, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("upstream: nameservers is required")
+	}
+	u := &Upstream{servers: servers, timeout: timeout}
+	switch strings.ToLower(strategy) {
+	case "", StrategyParallel:
+		u.parallel = true
+	case StrategySequential:
+		u.parallel = false
+	default:
+		return nil, fmt.Errorf("upstream: invalid strategy: %s", strategy)
+	}
+	if u.timeout <= 0 {
+		u.timeout = DefaultTimeout
+	}
+	return u, nil
+}
+
+// Lookup 查询域名的 A、AAAA 或 CNAME 记录
+func (u *Upstream) Lookup(ctx context.Context, name string, qtype dnsmessage.Type) (Answer, error) {
+	if u.parallel {
+		return u.lookupParallel(ctx, name, qtype)
+	}
+	return u.lookupSequential(ctx, name, qtype)
+}
+
+func (u *Upstream) lookupSequential(ctx context.Context, name string, qtype dnsmessage.Type) (Answer, error) {
+	var errs []error
+	for _, server := range u.servers {
+		answer, err := u.exchange(ctx, server, name, qtype)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return answer, err
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return Answer{}, errors.Join(errs...)
+}
+
+func (u *Upstream) lookupParallel(ctx context.Context, name string, qtype dnsmessage.Type) (Answer, error) {
+	type result struct {
+		answer Answer
+		err    error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(u.servers))
+	for _, server := range u.servers {
+		go func(server Nameserver) {
+			answer, err := u.exchange(ctx, server, name, qtype)
+			results <- result{answer: answer, err: err}
+		}(server)
+	}
+	var errs []error
+	for range u.servers {
+		r := <-results
+		if r.err == nil || errors.Is(r.err, ErrNotFound) {
+			return r.answer, r.err
+		}
+		errs = append(errs, r.err)
+	}
+	return Answer{}, errors.Join(errs...)
+}
+
+func (u *Upstream) exchange(ctx context.Context, server Nameserver, name string, qtype dnsmessage.Type) (Answer, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+	query, id, err := buildQuery(name, qtype)
+	if err != nil {
+		return Answer{}, fmt.Errorf("upstream: %s: %w", server, err)
+	}
+	resp, err := server.Exchange(ctx, query)
+	if err != nil {
+		return Answer{}, fmt.Errorf("upstream: %s: %w", server, err)
+	}
+	answer, err := parseAnswer(resp, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return answer, fmt.Errorf("upstream: %s: %w", server, err)
+	}
+	return answer, err
+}
+
+func buildQuery(name string, qtype dnsmessage.Type) ([]byte, uint16, error) {
+	qname, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid name: %s. %w", name, err)
+	}
+	id := uint16(rand.Uint32())
+	builder := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	_ = builder.StartQuestions()
+	_ = builder.Question(dnsmessage.Question{Name: qname, Type: qtype, Class: dnsmessage.ClassINET})
+	_ = builder.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	_ = opt.SetEDNS0(ednsUDPSize, dnsmessage.RCodeSuccess, false)
+	_ = builder.OPTResource(opt, dnsmessage.OPTResource{})
+	query, err := builder.Finish()
+	if err != nil {
+		return nil, 0, fmt.Errorf("build query. %w", err)
+	}
+	return query, id, nil
+}
+
+func parseAnswer(resp []byte, id uint16) (Answer, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(resp)
+	if err != nil {
+		return Answer{}, fmt.Errorf("parse response. %w", err)
+	}
+	if header.ID != id {
+		return Answer{}, fmt.Errorf("response id mismatch")
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return Answer{}, ErrNotFound
+	case dnsmessage.RCodeServerFailure:
+		return Answer{}, ErrServerFailure
+	default:
+		return Answer{}, fmt.Errorf("response rcode: %s", header.RCode)
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return Answer{}, fmt.Errorf("parse questions. %w", err)
+	}
+	var answer Answer
+	for i := 0; ; i++ {
+		rh, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return Answer{}, fmt.Errorf("parse answers. %w", err)
+		}
+		if i == 0 || rh.TTL < answer.TTL {
+			answer.TTL = rh.TTL
+		}
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			r, err := parser.AResource()
+			if err != nil {
+				return Answer{}, fmt.Errorf("parse A record. %w", err)
+			}
+			answer.IPs = append(answer.IPs, stdnet.IP(r.A[:]))
+		case dnsmessage.TypeAAAA:
+			r, err := parser.AAAAResource()
+			if err != nil {
+				return Answer{}, fmt.Errorf("parse AAAA record. %w", err)
+			}
+			answer.IPs = append(answer.IPs, stdnet.IP(r.AAAA[:]))
+		case dnsmessage.TypeCNAME:
+			r, err := parser.CNAMEResource()
+			if err != nil {
+				return Answer{}, fmt.Errorf("parse CNAME record. %w", err)
+			}
+			if answer.CNAME == "" {
+				answer.CNAME = strings.TrimSuffix(r.CNAME.String(), ".")
+			}
+		default:
+			if err := parser.SkipAnswer(); err != nil {
+				return Answer{}, fmt.Errorf("parse answers. %w", err)
+			}
+		}
+	}
+	return answer, nil
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,225 @@
+package feature
+
+import (
+	"context"
+	stdnet "net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bytepowered/cache"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/resolver"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/knadh/koanf/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeAnswer 模拟上游返回的应答：rcode 不为成功时不返回记录
+type fakeAnswer struct {
+	ip    string
+	ttl   uint32
+	rcode dnsmessage.RCode
+}
+
+// fakeNameserver 模拟的上游 DNS 服务器，按当前设置的应答回复 A 记录查询
+type fakeNameserver struct {
+	mu      sync.Mutex
+	answer  fakeAnswer
+	queries atomic.Int32
+}
+
+func (n *fakeNameserver) set(answer fakeAnswer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.answer = answer
+}
+
+func (n *fakeNameserver) Exchange(_ context.Context, query []byte) ([]byte, error) {
+	n.queries.Add(1)
+	n.mu.Lock()
+	answer := n.answer
+	n.mu.Unlock()
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, RCode: answer.rcode})
+	_ = builder.StartQuestions()
+	_ = builder.Question(question)
+	_ = builder.StartAnswers()
+	if answer.rcode == dnsmessage.RCodeSuccess && answer.ip != "" {
+		var a dnsmessage.AResource
+		copy(a.A[:], stdnet.ParseIP(answer.ip).To4())
+		_ = builder.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: answer.ttl}, a)
+	}
+	return builder.Finish()
+}
+
+func (n *fakeNameserver) String() string {
+	return "fake"
+}
+
+func TestCacheResolverLookup(t *testing.T) {
+	const name = "example.test"
+	type step struct {
+		at          time.Duration // 相对于首次查询的时间
+		upstream    *fakeAnswer   // 查询前替换上游应答；nil 时保持不变
+		wantIP      string
+		wantTTL     time.Duration
+		wantErr     error
+		wantQueries int32
+	}
+	answer := func(ip string, ttl uint32) *fakeAnswer { return &fakeAnswer{ip: ip, ttl: ttl} }
+	failure := func(rcode dnsmessage.RCode) *fakeAnswer { return &fakeAnswer{rcode: rcode} }
+	tests := []struct {
+		name         string
+		negativeTTL  time.Duration
+		staleTTL     time.Duration
+		prefetchHits int
+		steps        []step
+	}{
+		{"expiry", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 40 * time.Second, upstream: answer("10.0.0.2", 100), wantIP: "10.0.0.1", wantTTL: 60 * time.Second, wantQueries: 1},
+			{at: 101 * time.Second, wantIP: "10.0.0.2", wantTTL: 100 * time.Second, wantQueries: 2},
+		}},
+		{"ttl clamped", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 1), wantIP: "10.0.0.1", wantTTL: 5 * time.Second, wantQueries: 1},
+			{at: 3 * time.Second, wantIP: "10.0.0.1", wantTTL: 2 * time.Second, wantQueries: 1},
+			{at: 6 * time.Second, wantIP: "10.0.0.1", wantTTL: 5 * time.Second, wantQueries: 2},
+		}},
+		{"negative: nxdomain", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: failure(dnsmessage.RCodeNameError), wantErr: resolver.ErrNotFound, wantQueries: 1},
+			{at: 10 * time.Second, upstream: answer("10.0.0.1", 100), wantErr: resolver.ErrNotFound, wantQueries: 1},
+			{at: 31 * time.Second, wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 2},
+		}},
+		{"negative: servfail", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: failure(dnsmessage.RCodeServerFailure), wantErr: resolver.ErrServerFailure, wantQueries: 1},
+			{at: 29 * time.Second, wantErr: resolver.ErrServerFailure, wantQueries: 1},
+		}},
+		{"negative: no address", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: answer("", 100), wantErr: resolver.ErrNoAddress, wantQueries: 1},
+			{at: 29 * time.Second, wantErr: resolver.ErrNoAddress, wantQueries: 1},
+		}},
+		{"negative: disabled", 0, 0, 0, []step{
+			{at: 0, upstream: failure(dnsmessage.RCodeNameError), wantErr: resolver.ErrNotFound, wantQueries: 1},
+			{at: time.Second, wantErr: resolver.ErrNotFound, wantQueries: 2},
+		}},
+		{"stale: served and refreshed", 30 * time.Second, 60 * time.Second, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 120 * time.Second, upstream: answer("10.0.0.2", 100), wantIP: "10.0.0.1", wantTTL: staleAnswerTTL, wantQueries: 2},
+			{at: 121 * time.Second, wantIP: "10.0.0.2", wantTTL: 99 * time.Second, wantQueries: 2},
+		}},
+		{"stale: kept on server failure", 30 * time.Second, 60 * time.Second, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 120 * time.Second, upstream: failure(dnsmessage.RCodeServerFailure), wantIP: "10.0.0.1", wantTTL: staleAnswerTTL, wantQueries: 2},
+			{at: 121 * time.Second, wantIP: "10.0.0.1", wantTTL: staleAnswerTTL, wantQueries: 3},
+		}},
+		{"stale: window passed", 30 * time.Second, 60 * time.Second, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 161 * time.Second, upstream: answer("10.0.0.2", 100), wantIP: "10.0.0.2", wantTTL: 100 * time.Second, wantQueries: 2},
+		}},
+		{"stale: disabled", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 120 * time.Second, upstream: answer("10.0.0.2", 100), wantIP: "10.0.0.2", wantTTL: 100 * time.Second, wantQueries: 2},
+		}},
+		{"prefetch: hot record", 30 * time.Second, 0, 3, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 50 * time.Second, wantIP: "10.0.0.1", wantTTL: 50 * time.Second, wantQueries: 1},
+			{at: 91 * time.Second, upstream: answer("10.0.0.2", 100), wantIP: "10.0.0.1", wantTTL: 9 * time.Second, wantQueries: 2},
+			{at: 92 * time.Second, wantIP: "10.0.0.2", wantTTL: 99 * time.Second, wantQueries: 2},
+		}},
+		{"prefetch: below hits", 30 * time.Second, 0, 3, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 91 * time.Second, wantIP: "10.0.0.1", wantTTL: 9 * time.Second, wantQueries: 1},
+			{at: 95 * time.Second, wantIP: "10.0.0.1", wantTTL: 5 * time.Second, wantQueries: 2},
+		}},
+		{"prefetch: not near expiry", 30 * time.Second, 0, 1, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 89 * time.Second, wantIP: "10.0.0.1", wantTTL: 11 * time.Second, wantQueries: 1},
+			{at: 90 * time.Second, wantIP: "10.0.0.1", wantTTL: 10 * time.Second, wantQueries: 2},
+		}},
+		{"prefetch: disabled", 30 * time.Second, 0, 0, []step{
+			{at: 0, upstream: answer("10.0.0.1", 100), wantIP: "10.0.0.1", wantTTL: 100 * time.Second, wantQueries: 1},
+			{at: 95 * time.Second, wantIP: "10.0.0.1", wantTTL: 5 * time.Second, wantQueries: 1},
+			{at: 99 * time.Second, wantIP: "10.0.0.1", wantTTL: time.Second, wantQueries: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &fakeNameserver{}
+			d, clock := newTestCacheResolver(t, ns, Options{
+				NegativeTTL:  tt.negativeTTL,
+				StaleTTL:     tt.staleTTL,
+				PrefetchHits: tt.prefetchHits,
+			})
+			var elapsed time.Duration
+			for i, step := range tt.steps {
+				clock.Advance(step.at - elapsed)
+				elapsed = step.at
+				if step.upstream != nil {
+					ns.set(*step.upstream)
+				}
+				ips, ttl, err := d.Lookup(testResolveContext(), net.ParseDomainAddr(net.NetworkTCP, name))
+				if step.wantErr != nil {
+					assert.ErrorIs(t, err, step.wantErr, "step %d", i)
+				} else if assert.NoError(t, err, "step %d", i) && assert.Len(t, ips, 1, "step %d", i) {
+					assert.Equal(t, step.wantIP, ips[0].String(), "step %d", i)
+					assert.Equal(t, step.wantTTL, ttl, "step %d", i)
+				}
+				waitRefreshed(t, d, name)
+				assert.Equal(t, step.wantQueries, ns.queries.Load(), "step %d", i)
+			}
+		})
+	}
+}
+
+func TestCacheResolverStatic(t *testing.T) {
+	ns := &fakeNameserver{}
+	d, clock := newTestCacheResolver(t, ns, Options{StaleTTL: 60 * time.Second, PrefetchHits: 1})
+	d.Set("static.test", stdnet.ParseIP("10.0.0.9"))
+	for _, advance := range []time.Duration{0, time.Hour, 24 * 365 * time.Hour} {
+		clock.Advance(advance)
+		ips, ttl, err := d.Lookup(testResolveContext(), net.ParseDomainAddr(net.NetworkTCP, "static.test"))
+		require.NoError(t, err)
+		require.Len(t, ips, 1)
+		assert.Equal(t, "10.0.0.9", ips[0].String())
+		assert.Equal(t, d.defaultTTL, ttl)
+		waitRefreshed(t, d, "static.test")
+	}
+	assert.Zero(t, ns.queries.Load())
+}
+
+func newTestCacheResolver(t *testing.T, ns resolver.Nameserver, opts Options) (*CacheResolver, cache.FakeClock) {
+	upstream, err := resolver.NewUpstream([]resolver.Nameserver{ns}, resolver.StrategySequential, time.Second)
+	require.NoError(t, err)
+	opts.CacheSize = 16
+	opts.CacheTTL = 60 * time.Second
+	opts.MinTTL = 5 * time.Second
+	opts.MaxTTL = time.Hour
+	opts.Upstream = upstream
+	opts.IPStrategy = resolver.IPStrategyIPv4Only
+	clock := cache.NewFakeClock()
+	return newCacheResolver(opts, clock), clock
+}
+
+func testResolveContext() context.Context {
+	return context.WithValue(context.Background(), proxy.CtxKeyConfiger, koanf.New("."))
+}
+
+// waitRefreshed 等待后台刷新完成：缓存条目已被替换或已不在刷新中
+func waitRefreshed(t *testing.T, d *CacheResolver, key string) {
+	require.Eventually(t, func() bool {
+		v, err := d.cached.Get(key)
+		return err != nil || !v.(*cacheEntry).refreshing.Load()
+	}, time.Second, time.Millisecond)
+}
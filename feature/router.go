@@ -0,0 +1,60 @@
+package feature
+
+import (
+	"context"
+	"github.com/bytepowered/assert"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/router"
+	stdnet "net"
+	"sync"
+)
+
+var (
+	routerOnce = sync.Once{}
+	routerInst *Router
+)
+
+// Router 按规则顺序为连接选择出站的 Dialer 名称，均未匹配时使用默认出站
+type Router struct {
+	rules    []*router.Rule
+	fallback string
+}
+
+// Route 返回匹配的出站名称。仅在规则包含目标 CIDR 条件时，按需解析目标域名；
+// 启用 via_outbound 时出站尚未选定，不在本地解析域名，目标 CIDR 条件仅匹配 IP 目标地址。
+func (r *Router) Route(ctx context.Context, permit proxy.Permit) string {
+	var destIPs []stdnet.IP
+	resolved := false
+	resolve := func(ctx context.Context) []stdnet.IP {
+		if !resolved {
+			resolved = true
+			if UseResolver().ViaOutbound() {
+				return nil
+			}
+			if ips, err := UseResolver().Resolve(ctx, permit.Destination); err == nil {
+				destIPs = ips
+			} else {
+				proxy.Logger(ctx).Warnf("route: resolve: %s", err)
+			}
+		}
+		return destIPs
+	}
+	for _, rule := range r.rules {
+		if rule.Match(ctx, permit, resolve) {
+			return rule.Outbound
+		}
+	}
+	return r.fallback
+}
+
+func InitRouter(rules []*router.Rule, fallback string) *Router {
+	routerOnce.Do(func() {
+		routerInst = &Router{rules: rules, fallback: fallback}
+	})
+	return routerInst
+}
+
+func UseRouter() *Router {
+	assert.MustNotNil(routerInst, "router not initialized")
+	return routerInst
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"context"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/ruleset"
+	"github.com/fluxproxy/fluxproxy/net"
+	stdnet "net"
+	"slices"
+)
+
+// Rule 路由规则：不同类型的条件之间为“与”关系，同一类型的多个取值之间为“或”关系；
+// 域名的 suffix/keyword/regex 视为同一类型的条件。未配置的条件不参与匹配。
+type Rule struct {
+	Outbound string
+	// Domain 域名条件，与域名访问规则使用相同的匹配器；为 nil 时不参与匹配
+	Domain *ruleset.DomainMatcher
+	CIDR   []stdnet.IPNet
+	// GeoIP 目标 IP 地址所属的国家/地区代码（大写），由 GeoDatabase 查询
+	GeoIP       []string
+	GeoDatabase *ruleset.GeoDatabase
+	Ports       []net.PortRange
+	SourceCIDR  []stdnet.IPNet
+	Users       []string
+}
+
+// ResolveFunc 按需解析目标域名的 IP 地址，仅在规则包含目标 CIDR 或 GeoIP 条件时调用
+type ResolveFunc func(ctx context.Context) []stdnet.IP
+
+func (r *Rule) Match(ctx context.Context, permit proxy.Permit, resolve ResolveFunc) bool {
+	if r.Domain != nil && !r.matchDomain(permit.Destination) {
+		return false
+	}
+	if len(r.Ports) > 0 && !r.matchPort(permit.Destination.Port) {
+		return false
+	}
+	if len(r.SourceCIDR) > 0 && !matchIPNets(r.SourceCIDR, permit.Source.IP) {
+		return false
+	}
+	if len(r.Users) > 0 && !slices.Contains(r.Users, permit.Principal.Name) {
+		return false
+	}
+	if len(r.CIDR) == 0 && len(r.GeoIP) == 0 {
+		return true
+	}
+	destIPs := []stdnet.IP{permit.Destination.IP}
+	if permit.Destination.IsDomain() {
+		destIPs = resolve(ctx)
+	}
+	if len(r.CIDR) > 0 && !slices.ContainsFunc(destIPs, func(ip stdnet.IP) bool { return matchIPNets(r.CIDR, ip) }) {
+		return false
+	}
+	if len(r.GeoIP) > 0 && !slices.ContainsFunc(destIPs, func(ip stdnet.IP) bool { return r.matchGeoIP(ctx, ip) }) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) matchGeoIP(ctx context.Context, ip stdnet.IP) bool {
+	if ip == nil {
+		return false
+	}
+	code, err := r.GeoDatabase.Country(ip)
+	if err != nil {
+		proxy.Logger(ctx).Warnf("route: geoip: lookup: %s: %s", ip, err)
+		return false
+	}
+	return code != "" && slices.Contains(r.GeoIP, code)
+}
+
+func (r *Rule) matchDomain(dest net.Address) bool {
+	return dest.IsDomain() && r.Domain.Match(dest.Domain)
+}
+
+func (r *Rule) matchPort(port int) bool {
+	for _, portRange := range r.Ports {
+		if portRange.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIPNets(nets []stdnet.IPNet, ip stdnet.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"context"
+	stdnet "net"
+	"testing"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/feature/ruleset"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resolveTo(ips ...string) ResolveFunc {
+	return func(ctx context.Context) []stdnet.IP {
+		var out []stdnet.IP
+		for _, ip := range ips {
+			out = append(out, stdnet.ParseIP(ip))
+		}
+		return out
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	domain := func(patterns ...string) *ruleset.DomainMatcher {
+		matcher, err := ruleset.NewDomainMatcher(patterns)
+		require.NoError(t, err)
+		return matcher
+	}
+	web, err := net.ParsePortRange("443")
+	require.NoError(t, err)
+	_, lan, _ := stdnet.ParseCIDR("192.168.0.0/16")
+	source := net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("192.168.1.10"))
+	tests := []struct {
+		name string
+		rule Rule
+		dest net.Address
+		user string
+		want bool
+	}{
+		{"empty", Rule{}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "", true},
+		{"suffix", Rule{Domain: domain("domain:example.com")}, net.ParseDomainAddr(net.NetworkTCP, "www.Example.com."), "", true},
+		{"suffix self", Rule{Domain: domain("domain:example.com")}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "", true},
+		{"suffix label", Rule{Domain: domain("domain:example.com")}, net.ParseDomainAddr(net.NetworkTCP, "badexample.com"), "", false},
+		{"keyword", Rule{Domain: domain("keyword:Tracker")}, net.ParseDomainAddr(net.NetworkTCP, "a.tracker.net"), "", true},
+		{"regexp", Rule{Domain: domain(`regexp:^ad[0-9]+\.`)}, net.ParseDomainAddr(net.NetworkTCP, "ad1.example.com"), "", true},
+		{"domain or", Rule{Domain: domain("domain:example.org", "keyword:example")}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "", true},
+		{"domain ip destination", Rule{Domain: domain("domain:example.com")}, net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("10.0.0.1")), "", false},
+		{"domain and port", Rule{Domain: domain("domain:example.com"), Ports: []net.PortRange{web}}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "", false},
+		{"source", Rule{SourceCIDR: []stdnet.IPNet{*lan}}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "", true},
+		{"user", Rule{Users: []string{"alice"}}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "alice", true},
+		{"other user", Rule{Users: []string{"alice"}}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), "bob", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permit := proxy.Permit{Source: source, Destination: tt.dest, Principal: proxy.Principal{Name: tt.user}}
+			assert.Equal(t, tt.want, tt.rule.Match(context.Background(), permit, resolveTo()))
+		})
+	}
+}
+
+func TestRuleMatchGeoIP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 1.0.0.0/24 country=CN；8.8.8.0/24 country=US；203.0.113.0/24 registered_country=JP
+	db, err := ruleset.OpenGeoDatabase(ctx, "../ruleset/testdata/geo.mmdb")
+	require.NoError(t, err)
+	_, tenNet, _ := stdnet.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		name    string
+		rule    Rule
+		dest    net.Address
+		resolve ResolveFunc
+		want    bool
+	}{
+		{"ip", Rule{GeoIP: []string{"CN"}}, net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("1.0.0.1")), nil, true},
+		{"other country", Rule{GeoIP: []string{"CN"}}, net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("8.8.8.8")), nil, false},
+		{"registered country", Rule{GeoIP: []string{"JP"}}, net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("203.0.113.1")), nil, true},
+		{"not in database", Rule{GeoIP: []string{"CN", "US", "JP"}}, net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("192.0.2.1")), nil, false},
+		{"resolved domain", Rule{GeoIP: []string{"US"}}, net.ParseDomainAddr(net.NetworkTCP, "dns.google"), resolveTo("8.8.8.8"), true},
+		{"any resolved ip", Rule{GeoIP: []string{"US"}}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), resolveTo("192.0.2.1", "8.8.8.4"), true},
+		{"unresolved domain", Rule{GeoIP: []string{"US"}}, net.ParseDomainAddr(net.NetworkTCP, "example.com"), resolveTo(), false},
+		{"and cidr", Rule{GeoIP: []string{"US"}, CIDR: []stdnet.IPNet{*tenNet}}, net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP("8.8.8.8")), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.GeoDatabase = db
+			assert.Equal(t, tt.want, tt.rule.Match(context.Background(), proxy.Permit{Destination: tt.dest}, tt.resolve))
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"github.com/bytepowered/assert"
 	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	stdnet "net"
 	"sync"
 )
 
@@ -34,6 +36,49 @@ func (c *MultiRuleset) Allow(ctx context.Context, permit proxy.Permit) error {
 	return proxy.ErrNoRulesetMatched
 }
 
+// AllowResolved 逐个检查域名解析得到的地址，返回未被拒绝的地址；全部被拒绝时返回首个拒绝原因。
+// 检查时保留原域名，使域名规则在两次检查中结果一致；onDeny 不为空时，对每个被拒绝的地址调用。
+func (c *MultiRuleset) AllowResolved(ctx context.Context, permit proxy.Permit, ips []stdnet.IP, onDeny func(ip stdnet.IP, err error)) ([]stdnet.IP, error) {
+	destAddr := permit.Destination
+	allowed := make([]stdnet.IP, 0, len(ips))
+	var denyErr error
+	for _, ip := range ips {
+		permit.Destination = net.Address{
+			Network: destAddr.Network,
+			Family:  net.ToAddressFamily(ip),
+			IP:      ip,
+			Domain:  destAddr.Domain,
+			Port:    destAddr.Port,
+		}
+		err := c.Allow(ctx, permit)
+		if err == nil || errors.Is(err, proxy.ErrNoRulesetMatched) {
+			allowed = append(allowed, ip)
+			continue
+		}
+		if denyErr == nil {
+			denyErr = err
+		}
+		if onDeny != nil {
+			onDeny(ip, err)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, denyErr
+	}
+	return allowed, nil
+}
+
+// Select 返回仅包含满足条件的规则的 MultiRuleset，规则顺序不变
+func (c *MultiRuleset) Select(match func(proxy.Ruleset) bool) *MultiRuleset {
+	rulesets := make([]proxy.Ruleset, 0, len(c.rulesets))
+	for _, ruleset := range c.rulesets {
+		if match(ruleset) {
+			rulesets = append(rulesets, ruleset)
+		}
+	}
+	return &MultiRuleset{rulesets: rulesets}
+}
+
 func InitMultiRuleset(ruleset []proxy.Ruleset) *MultiRuleset {
 	rulesetOnce.Do(func() {
 		rulesetInst = &MultiRuleset{rulesets: ruleset}
@@ -0,0 +1,152 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	_ proxy.Ruleset = (*Domain)(nil)
+)
+
+// domain pattern prefix
+const (
+	DomainPrefixFull    = "full:"
+	DomainPrefixSuffix  = "domain:"
+	DomainPrefixKeyword = "keyword:"
+	DomainPrefixRegexp  = "regexp:"
+	DomainPrefixGlob    = "glob:"
+)
+
+// DomainMatcher 域名匹配：完整域名、后缀、关键字、正则表达式及通配符
+type DomainMatcher struct {
+	full     map[string]struct{}
+	suffix   *net.DomainTrie
+	keywords []string
+	regexps  []*regexp.Regexp
+	globs    []string
+}
+
+// NewDomainMatcher 解析域名规则列表，规则格式：
+//
+//	full:www.example.com    完整匹配
+//	domain:example.com      匹配域名及其子域名；无前缀时的默认方式
+//	keyword:example         包含关键字
+//	regexp:^ad[0-9]+\.      正则表达式
+//	glob:*.example.*        通配符；无前缀且包含 * 或 ? 时的默认方式
+func NewDomainMatcher(patterns []string) (*DomainMatcher, error) {
+	m := &DomainMatcher{
+		full:   make(map[string]struct{}),
+		suffix: net.NewDomainTrie(),
+	}
+	for _, pattern := range patterns {
+		if err := m.Add(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *DomainMatcher) Add(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	switch {
+	case strings.HasPrefix(pattern, DomainPrefixFull):
+		m.full[net.NormalizeDomain(pattern[len(DomainPrefixFull):])] = struct{}{}
+	case strings.HasPrefix(pattern, DomainPrefixSuffix):
+		m.suffix.Insert(pattern[len(DomainPrefixSuffix):])
+	case strings.HasPrefix(pattern, DomainPrefixKeyword):
+		m.keywords = append(m.keywords, strings.ToLower(pattern[len(DomainPrefixKeyword):]))
+	case strings.HasPrefix(pattern, DomainPrefixRegexp):
+		regex, err := regexp.Compile(pattern[len(DomainPrefixRegexp):])
+		if err != nil {
+			return fmt.Errorf("domain: invalid regexp: %s. %w", pattern, err)
+		}
+		m.regexps = append(m.regexps, regex)
+	case strings.HasPrefix(pattern, DomainPrefixGlob):
+		return m.addGlob(pattern[len(DomainPrefixGlob):])
+	case strings.ContainsAny(pattern, "*?"):
+		return m.addGlob(pattern)
+	case pattern == "":
+		return fmt.Errorf("domain: empty pattern")
+	default:
+		m.suffix.Insert(pattern)
+	}
+	return nil
+}
+
+func (m *DomainMatcher) addGlob(glob string) error {
+	glob = net.NormalizeDomain(glob)
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("domain: invalid glob: %s. %w", glob, err)
+	}
+	m.globs = append(m.globs, glob)
+	return nil
+}
+
+func (m *DomainMatcher) Match(domain string) bool {
+	domain = net.NormalizeDomain(domain)
+	if domain == "" {
+		return false
+	}
+	if _, ok := m.full[domain]; ok {
+		return true
+	}
+	if m.suffix.Match(domain) {
+		return true
+	}
+	for _, keyword := range m.keywords {
+		if strings.Contains(domain, keyword) {
+			return true
+		}
+	}
+	for _, regex := range m.regexps {
+		if regex.MatchString(domain) {
+			return true
+		}
+	}
+	for _, glob := range m.globs {
+		if ok, _ := path.Match(glob, domain); ok {
+			return true
+		}
+	}
+	return false
+}
+
+////
+
+// Domain 按域名匹配的访问规则，仅检查域名形式的地址
+type Domain struct {
+	useSource bool
+	isAllow   bool
+	matcher   *DomainMatcher
+}
+
+func NewDomain(isAllow bool, useSource bool, matcher *DomainMatcher) *Domain {
+	return &Domain{
+		isAllow:   isAllow,
+		useSource: useSource,
+		matcher:   matcher,
+	}
+}
+
+func (d *Domain) Allow(ctx context.Context, permit proxy.Permit) error {
+	var target net.Address
+	if d.useSource {
+		target = permit.Source
+	} else {
+		target = permit.Destination
+	}
+	// 解析后再次检查时，目标地址仍保留原域名
+	if target.Domain == "" || !d.matcher.Match(target.Domain) {
+		return proxy.ErrNoRulesetMatched
+	}
+	if d.isAllow {
+		return nil
+	}
+	return fmt.Errorf("domain: deny: %s", target)
+}
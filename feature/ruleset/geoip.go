@@ -0,0 +1,184 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/sirupsen/logrus"
+	stdnet "net"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	_ proxy.Ruleset = (*GeoIP)(nil)
+	_ proxy.Ruleset = (*ASN)(nil)
+)
+
+// GeoDatabase MaxMind/DB-IP 格式（.mmdb）的数据库，文件变更时自动重新加载
+type GeoDatabase struct {
+	path   string
+	reader atomic.Pointer[maxminddb.Reader]
+}
+
+func OpenGeoDatabase(runCtx context.Context, path string) (*GeoDatabase, error) {
+	db := &GeoDatabase{path: path}
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+	if err := helper.WatchFiles(runCtx, []string{path}, db.reload); err != nil {
+		return nil, fmt.Errorf("geoip: watch file. %w", err)
+	}
+	return db, nil
+}
+
+func (db *GeoDatabase) Lookup(ip stdnet.IP, record any) error {
+	return db.reader.Load().Lookup(ip, record)
+}
+
+// Country 返回 IP 地址所属的国家/地区代码（大写），无国家信息时使用注册国家；未收录时返回空字符串
+func (db *GeoDatabase) Country(ip stdnet.IP) (string, error) {
+	var record geoCountryRecord
+	if err := db.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	if code := record.Country.ISOCode; code != "" {
+		return code, nil
+	}
+	return record.RegisteredCountry.ISOCode, nil
+}
+
+func (db *GeoDatabase) reload() {
+	if err := db.load(); err != nil {
+		logrus.Errorf("geoip: reload: %s", err)
+	} else {
+		logrus.Infof("geoip: reload: %s", db.path)
+	}
+}
+
+func (db *GeoDatabase) load() error {
+	// 读取到内存中，替换后旧的 Reader 由 GC 回收，不影响正在进行的查询
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return fmt.Errorf("geoip: read database. %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("geoip: parse database. %w", err)
+	}
+	db.reader.Store(reader)
+	return nil
+}
+
+////
+
+type geoCountryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// GeoIP 按 IP 地址所属国家/地区代码匹配的访问规则
+type GeoIP struct {
+	useSource bool
+	isAllow   bool
+	db        *GeoDatabase
+	countries []string
+}
+
+func NewGeoIP(isAllow bool, useSource bool, db *GeoDatabase, countries []string) *GeoIP {
+	codes := make([]string, 0, len(countries))
+	for _, country := range countries {
+		codes = append(codes, strings.ToUpper(country))
+	}
+	return &GeoIP{
+		isAllow:   isAllow,
+		useSource: useSource,
+		db:        db,
+		countries: codes,
+	}
+}
+
+func (g *GeoIP) Allow(ctx context.Context, permit proxy.Permit) error {
+	target := selectTarget(permit, g.useSource)
+	if target.IP == nil {
+		return proxy.ErrNoRulesetMatched
+	}
+	code, err := g.db.Country(target.IP)
+	if err != nil {
+		proxy.Logger(ctx).Warnf("geoip: lookup: %s: %s", target.IP, err)
+		return proxy.ErrNoRulesetMatched
+	}
+	if code == "" || !slices.Contains(g.countries, code) {
+		return proxy.ErrNoRulesetMatched
+	}
+	if g.isAllow {
+		return nil
+	}
+	return fmt.Errorf("geoip: deny: %s: %s", code, target)
+}
+
+////
+
+func (g *GeoIP) needsDestinationIP() bool {
+	return !g.useSource
+}
+
+type geoASNRecord struct {
+	AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+}
+
+// ASN 按 IP 地址所属自治系统编号匹配的访问规则
+type ASN struct {
+	useSource bool
+	isAllow   bool
+	db        *GeoDatabase
+	numbers   []uint
+}
+
+func NewASN(isAllow bool, useSource bool, db *GeoDatabase, numbers []uint) *ASN {
+	return &ASN{
+		isAllow:   isAllow,
+		useSource: useSource,
+		db:        db,
+		numbers:   numbers,
+	}
+}
+
+func (a *ASN) Allow(ctx context.Context, permit proxy.Permit) error {
+	target := selectTarget(permit, a.useSource)
+	if target.IP == nil {
+		return proxy.ErrNoRulesetMatched
+	}
+	var record geoASNRecord
+	if err := a.db.Lookup(target.IP, &record); err != nil {
+		proxy.Logger(ctx).Warnf("asn: lookup: %s: %s", target.IP, err)
+		return proxy.ErrNoRulesetMatched
+	}
+	if record.AutonomousSystemNumber == 0 || !slices.Contains(a.numbers, record.AutonomousSystemNumber) {
+		return proxy.ErrNoRulesetMatched
+	}
+	if a.isAllow {
+		return nil
+	}
+	return fmt.Errorf("asn: deny: AS%d: %s", record.AutonomousSystemNumber, target)
+}
+
+func (a *ASN) needsDestinationIP() bool {
+	return !a.useSource
+}
+
+func selectTarget(permit proxy.Permit, useSource bool) net.Address {
+	if useSource {
+		return permit.Source
+	}
+	return permit.Destination
+}
@@ -0,0 +1,108 @@
+package ruleset
+
+import (
+	"context"
+	stdnet "net"
+	"testing"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testdata/geo.mmdb 为 IPv4 测试数据库：
+//
+//	1.0.0.0/24      country=CN registered_country=CN asn=4134
+//	8.8.8.0/24      country=US registered_country=US asn=15169
+//	203.0.113.0/24  registered_country=JP
+func openTestGeoDatabase(t *testing.T) *GeoDatabase {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	db, err := OpenGeoDatabase(ctx, "testdata/geo.mmdb")
+	require.NoError(t, err)
+	return db
+}
+
+func TestGeoIPAllow(t *testing.T) {
+	db := openTestGeoDatabase(t)
+	tests := []struct {
+		name      string
+		countries []string
+		useSource bool
+		source    string
+		domain    string
+		ip        string
+		wantDeny  bool
+	}{
+		{"country", []string{"CN"}, false, "", "", "1.0.0.1", true},
+		{"country lower case", []string{"cn"}, false, "", "", "1.0.0.1", true},
+		{"other country", []string{"CN"}, false, "", "", "8.8.8.8", false},
+		{"registered country", []string{"JP"}, false, "", "", "203.0.113.5", true},
+		{"not in database", []string{"CN", "US", "JP"}, false, "", "", "192.0.2.1", false},
+		{"resolved domain", []string{"US"}, false, "", "dns.google", "8.8.8.8", true},
+		{"before resolve", []string{"US"}, false, "", "dns.google", "", false},
+		{"source", []string{"CN"}, true, "1.0.0.1", "", "8.8.8.8", true},
+		{"source other country", []string{"US"}, true, "1.0.0.1", "", "8.8.8.8", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGeoIP(false, tt.useSource, db, tt.countries)
+			permit := proxy.Permit{Destination: testDest(tt.domain, tt.ip)}
+			if tt.source != "" {
+				permit.Source = net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP(tt.source))
+			}
+			err := g.Allow(context.Background(), permit)
+			if tt.wantDeny {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			} else {
+				assert.ErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			}
+		})
+	}
+}
+
+func TestASNAllow(t *testing.T) {
+	db := openTestGeoDatabase(t)
+	tests := []struct {
+		name     string
+		numbers  []uint
+		domain   string
+		ip       string
+		wantDeny bool
+	}{
+		{"asn", []uint{15169}, "", "8.8.8.8", true},
+		{"other asn", []uint{15169}, "", "1.0.0.1", false},
+		{"no asn", []uint{15169}, "", "203.0.113.5", false},
+		{"not in database", []uint{15169}, "", "192.0.2.1", false},
+		{"before resolve", []uint{15169}, "dns.google", "", false},
+		{"resolved domain", []uint{4134, 15169}, "dns.google", "8.8.8.8", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewASN(false, false, db, tt.numbers)
+			err := a.Allow(context.Background(), proxy.Permit{Destination: testDest(tt.domain, tt.ip)})
+			if tt.wantDeny {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			} else {
+				assert.ErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			}
+		})
+	}
+}
+
+func TestGeoIPUndecided(t *testing.T) {
+	db := openTestGeoDatabase(t)
+	geoip := NewGeoIP(true, false, db, []string{"US"})
+	assert.True(t, NeedsDestinationIP(geoip))
+	assert.True(t, NeedsDestinationIP(NewASN(true, false, db, []uint{15169})))
+	assert.False(t, NeedsDestinationIP(NewGeoIP(true, true, db, []string{"US"})))
+	// not geoip(US)：解析前无法判断，解析后按 IP 地址判断
+	notUS, err := NewLogical(false, LogicalNot, []proxy.Ruleset{geoip})
+	require.NoError(t, err)
+	assert.ErrorIs(t, notUS.Allow(context.Background(), proxy.Permit{Destination: testDest("example.com", "")}), proxy.ErrNoRulesetMatched)
+	assert.ErrorIs(t, notUS.Allow(context.Background(), proxy.Permit{Destination: testDest("example.com", "8.8.8.8")}), proxy.ErrNoRulesetMatched)
+	assert.Error(t, notUS.Allow(context.Background(), proxy.Permit{Destination: testDest("example.com", "1.0.0.1")}))
+}
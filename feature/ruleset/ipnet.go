@@ -15,14 +15,18 @@ var (
 type IPNet struct {
 	useSource bool
 	isAllow   bool
-	nets      []stdnet.IPNet
+	nets      *net.CIDRSet
 }
 
 func NewIPNet(isAllow bool, useSource bool, nets []stdnet.IPNet) *IPNet {
+	set := net.NewCIDRSet()
+	for _, ipNet := range nets {
+		set.Add(ipNet)
+	}
 	return &IPNet{
 		isAllow:   isAllow,
 		useSource: useSource,
-		nets:      nets,
+		nets:      set,
 	}
 }
 
@@ -44,11 +48,10 @@ func (i *IPNet) Allow(ctx context.Context, permit proxy.Permit) error {
 	}
 }
 
+func (i *IPNet) needsDestinationIP() bool {
+	return !i.useSource
+}
+
 func (i *IPNet) match(target net.Address) bool {
-	for _, r := range i.nets {
-		if r.Contains(target.IP) {
-			return true
-		}
-	}
-	return false
+	return i.nets.Contains(target.IP)
 }
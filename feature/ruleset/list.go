@@ -0,0 +1,231 @@
+package ruleset
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/helper"
+	"github.com/sirupsen/logrus"
+	stdnet "net"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ proxy.Ruleset = (*ListRuleset)(nil)
+)
+
+// hosts 文件中的本机条目，不作为规则
+var hostsLocalNames = map[string]struct{}{
+	"localhost": {}, "localhost.localdomain": {}, "local": {}, "broadcasthost": {},
+	"ip6-localhost": {}, "ip6-loopback": {}, "ip6-localnet": {}, "ip6-mcastprefix": {},
+	"ip6-allnodes": {}, "ip6-allrouters": {}, "ip6-allhosts": {}, "0.0.0.0": {},
+}
+
+// ListEntries 规则列表文件解析得到的条目
+type ListEntries struct {
+	Domains []string // 域名规则，格式同 NewDomainMatcher
+	CIDRs   []stdnet.IPNet
+	Invalid int // 无法识别的行数
+}
+
+// LoadListFiles 解析规则列表文件，每行一条规则，支持以下格式：
+//
+//	example.com / full:www.example.com   域名规则，格式同 NewDomainMatcher
+//	10.0.0.0/8 / 192.168.1.1             网段或 IP 地址
+//	0.0.0.0 ads.example.com              hosts 格式，按完整域名匹配
+//	DOMAIN-SUFFIX,example.com            DOMAIN/DOMAIN-SUFFIX/DOMAIN-KEYWORD/DOMAIN-REGEX/IP-CIDR/IP-CIDR6
+//
+// 以 #、! 或 // 开头的行为注释。域名与网段条目均会解析，由引用列表的规则选用其中一类。
+func LoadListFiles(paths []string) (ListEntries, error) {
+	var entries ListEntries
+	for _, path := range paths {
+		if err := loadListFile(path, &entries); err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+func loadListFile(path string, entries *ListEntries) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file. %w", err)
+	}
+	defer helper.Close(file)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if !parseListLine(scanner.Text(), entries) {
+			entries.Invalid++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read file: %s. %w", path, err)
+	}
+	return nil
+}
+
+func parseListLine(line string, entries *ListEntries) bool {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "//") {
+		return true
+	}
+	// 已带匹配方式前缀的域名规则
+	for _, prefix := range []string{DomainPrefixFull, DomainPrefixSuffix, DomainPrefixKeyword, DomainPrefixGlob} {
+		if strings.HasPrefix(line, prefix) {
+			entries.Domains = append(entries.Domains, line)
+			return true
+		}
+	}
+	if strings.HasPrefix(line, DomainPrefixRegexp) {
+		return appendRegexp(line, entries)
+	}
+	// DOMAIN-SUFFIX,example.com
+	if kind, value, ok := strings.Cut(line, ","); ok {
+		value, _, _ = strings.Cut(value, ",")
+		// 去除行尾注释
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(strings.TrimSpace(kind)) {
+		case "DOMAIN":
+			entries.Domains = append(entries.Domains, DomainPrefixFull+value)
+		case "DOMAIN-SUFFIX":
+			entries.Domains = append(entries.Domains, DomainPrefixSuffix+value)
+		case "DOMAIN-KEYWORD":
+			entries.Domains = append(entries.Domains, DomainPrefixKeyword+value)
+		case "DOMAIN-REGEX":
+			return appendRegexp(DomainPrefixRegexp+value, entries)
+		case "IP-CIDR", "IP-CIDR6":
+			return appendCIDR(value, entries)
+		default:
+			return false
+		}
+		return true
+	}
+	// 去除行尾注释
+	if i := strings.Index(line, "#"); i > 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	// hosts: 0.0.0.0 ads.example.com [...]
+	if fields := strings.Fields(line); len(fields) > 1 {
+		if stdnet.ParseIP(fields[0]) == nil {
+			return false
+		}
+		for _, host := range fields[1:] {
+			if _, local := hostsLocalNames[strings.ToLower(host)]; !local {
+				entries.Domains = append(entries.Domains, DomainPrefixFull+host)
+			}
+		}
+		return true
+	}
+	if strings.Contains(line, "/") || stdnet.ParseIP(line) != nil {
+		return appendCIDR(line, entries)
+	}
+	entries.Domains = append(entries.Domains, line)
+	return true
+}
+
+func appendRegexp(pattern string, entries *ListEntries) bool {
+	if _, err := regexp.Compile(pattern[len(DomainPrefixRegexp):]); err != nil {
+		return false
+	}
+	entries.Domains = append(entries.Domains, pattern)
+	return true
+}
+
+func appendCIDR(s string, entries *ListEntries) bool {
+	if _, ipNet, err := stdnet.ParseCIDR(s); err == nil {
+		entries.CIDRs = append(entries.CIDRs, *ipNet)
+		return true
+	}
+	ip := stdnet.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	entries.CIDRs = append(entries.CIDRs, stdnet.IPNet{IP: ip, Mask: stdnet.CIDRMask(bits, bits)})
+	return true
+}
+
+// ListRuleset 引用外部规则列表文件的访问规则。
+// 文件变更时重新加载并以原子方式替换规则，加载失败时保留原规则。
+type ListRuleset struct {
+	name    string
+	files   []string
+	build   func(ListEntries) (proxy.Ruleset, error)
+	current atomic.Pointer[proxy.Ruleset]
+}
+
+func NewListRuleset(runCtx context.Context, name string, files []string, build func(ListEntries) (proxy.Ruleset, error)) (*ListRuleset, error) {
+	l := &ListRuleset{
+		name:  name,
+		files: files,
+		build: build,
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	if err := helper.WatchFiles(runCtx, files, l.reload); err != nil {
+		return nil, fmt.Errorf("list: %s: watch files. %w", name, err)
+	}
+	return l, nil
+}
+
+func (l *ListRuleset) Allow(ctx context.Context, permit proxy.Permit) error {
+	return (*l.current.Load()).Allow(ctx, permit)
+}
+
+func (l *ListRuleset) needsDestinationIP() bool {
+	return NeedsDestinationIP(*l.current.Load())
+}
+
+func (l *ListRuleset) reload() {
+	if err := l.load(); err != nil {
+		logrus.Errorf("%s. keep previous rules", err)
+	}
+}
+
+func (l *ListRuleset) load() error {
+	start := time.Now()
+	entries, err := LoadListFiles(l.files)
+	if err != nil {
+		return fmt.Errorf("list: %s: %w", l.name, err)
+	}
+	inst, err := l.build(entries)
+	if err != nil {
+		return fmt.Errorf("list: %s: build. %w", l.name, err)
+	}
+	l.current.Store(&inst)
+	ignored := ignoredEntries(inst, entries)
+	if ignored > 0 {
+		logrus.Warnf("list: %s: ignored %d entries of other kind", l.name, ignored)
+	}
+	logrus.WithField("domains", len(entries.Domains)).
+		WithField("cidrs", len(entries.CIDRs)).
+		WithField("invalid", entries.Invalid).
+		WithField("ignored", ignored).
+		WithField("duration", time.Since(start).String()).
+		Infof("list: %s: loaded: %s", l.name, strings.Join(l.files, ","))
+	return nil
+}
+
+// ignoredEntries 返回规则不使用的条目数：ipnet 规则忽略域名条目，domain 规则忽略网段条目
+func ignoredEntries(inst proxy.Ruleset, entries ListEntries) int {
+	switch inst.(type) {
+	case *IPNet:
+		return len(entries.Domains)
+	case *Domain:
+		return len(entries.CIDRs)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,84 @@
+package ruleset
+
+import (
+	stdnet "net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantOK      bool
+		wantDomains []string
+		wantCIDRs   []string
+	}{
+		// 空行及注释
+		{"blank", "   ", true, nil, nil},
+		{"hash comment", "# example.com", true, nil, nil},
+		{"adblock comment", "! example.com", true, nil, nil},
+		{"slash comment", "// example.com", true, nil, nil},
+		// 带前缀的域名规则
+		{"full", "full:www.example.com", true, []string{"full:www.example.com"}, nil},
+		{"suffix", "domain:example.com", true, []string{"domain:example.com"}, nil},
+		{"keyword", "keyword:tracker", true, []string{"keyword:tracker"}, nil},
+		{"glob", "glob:ad*.example.com", true, []string{"glob:ad*.example.com"}, nil},
+		{"regexp", `regexp:^ad[0-9]+\.`, true, []string{`regexp:^ad[0-9]+\.`}, nil},
+		{"invalid regexp", "regexp:(", false, nil, nil},
+		// KIND,value
+		{"clash domain", "DOMAIN,www.example.com", true, []string{"full:www.example.com"}, nil},
+		{"clash suffix", "DOMAIN-SUFFIX,example.com", true, []string{"domain:example.com"}, nil},
+		{"clash keyword", "domain-keyword, tracker", true, []string{"keyword:tracker"}, nil},
+		{"clash regex", `DOMAIN-REGEX,^ad[0-9]+\.`, true, []string{`regexp:^ad[0-9]+\.`}, nil},
+		{"clash invalid regex", "DOMAIN-REGEX,(", false, nil, nil},
+		{"clash cidr", "IP-CIDR,10.0.0.0/8,no-resolve", true, nil, []string{"10.0.0.0/8"}},
+		{"clash cidr6", "IP-CIDR6,2001:db8::/32", true, nil, []string{"2001:db8::/32"}},
+		{"clash invalid cidr", "IP-CIDR,example.com", false, nil, nil},
+		{"clash trailing comment", "DOMAIN-SUFFIX,example.com # ads", true, []string{"domain:example.com"}, nil},
+		{"clash unknown kind", "GEOIP,CN", false, nil, nil},
+		// hosts
+		{"hosts", "0.0.0.0 ads.example.com", true, []string{"full:ads.example.com"}, nil},
+		{"hosts multiple", "127.0.0.1\tads.example.com tracker.example.com", true, []string{"full:ads.example.com", "full:tracker.example.com"}, nil},
+		{"hosts local names", "127.0.0.1 localhost localhost.localdomain", true, nil, nil},
+		{"hosts ipv6 local names", "::1 ip6-localhost ip6-loopback", true, nil, nil},
+		{"hosts trailing comment", "0.0.0.0 ads.example.com # ads", true, []string{"full:ads.example.com"}, nil},
+		{"hosts invalid address", "ads example.com", false, nil, nil},
+		// 网段及 IP 地址
+		{"cidr", "192.168.0.0/16", true, nil, []string{"192.168.0.0/16"}},
+		{"cidr normalized", "192.168.1.1/16", true, nil, []string{"192.168.0.0/16"}},
+		{"ipv4", "192.168.1.1", true, nil, []string{"192.168.1.1/32"}},
+		{"ipv6", "2001:db8::1", true, nil, []string{"2001:db8::1/128"}},
+		{"cidr trailing comment", "10.0.0.0/8 # intranet", true, nil, []string{"10.0.0.0/8"}},
+		{"invalid cidr", "10.0.0.0/33", false, nil, nil},
+		// 域名
+		{"domain", "example.com", true, []string{"example.com"}, nil},
+		{"domain trailing comment", "example.com # ads", true, []string{"example.com"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entries ListEntries
+			assert.Equal(t, tt.wantOK, parseListLine(tt.line, &entries))
+			assert.Equal(t, tt.wantDomains, entries.Domains)
+			var cidrs []string
+			for _, ipNet := range entries.CIDRs {
+				cidrs = append(cidrs, ipNet.String())
+			}
+			assert.Equal(t, tt.wantCIDRs, cidrs)
+		})
+	}
+}
+
+func TestIgnoredEntries(t *testing.T) {
+	_, ipNet, _ := stdnet.ParseCIDR("10.0.0.0/8")
+	entries := ListEntries{
+		Domains: []string{"example.com", "full:www.example.com"},
+		CIDRs:   []stdnet.IPNet{*ipNet},
+	}
+	matcher, err := NewDomainMatcher(entries.Domains)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ignoredEntries(NewIPNet(false, false, entries.CIDRs), entries))
+	assert.Equal(t, 1, ignoredEntries(NewDomain(false, false, matcher), entries))
+	assert.Equal(t, 0, ignoredEntries(NewPort(false, false, nil), entries))
+}
@@ -0,0 +1,158 @@
+package ruleset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"slices"
+	"strings"
+)
+
+var (
+	_ proxy.Ruleset = (*Logical)(nil)
+)
+
+// logical operator
+const (
+	LogicalAnd = "and"
+	LogicalOr  = "or"
+	LogicalNot = "not"
+)
+
+// Logical 以 and/or/not 组合多个规则条件的访问规则。
+// 条件规则返回 ErrNoRulesetMatched 时视为不匹配，否则（放行或拒绝）视为匹配。
+type Logical struct {
+	isAllow    bool
+	op         string
+	conditions []proxy.Ruleset
+}
+
+func NewLogical(isAllow bool, op string, conditions []proxy.Ruleset) (*Logical, error) {
+	op = strings.ToLower(op)
+	switch op {
+	case LogicalAnd, LogicalOr:
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("logical: %s: requires at least one condition", op)
+		}
+	case LogicalNot:
+		if len(conditions) != 1 {
+			return nil, fmt.Errorf("logical: not: requires exactly one condition, was: %d", len(conditions))
+		}
+	default:
+		return nil, fmt.Errorf("logical: invalid op: %s", op)
+	}
+	return &Logical{
+		isAllow:    isAllow,
+		op:         op,
+		conditions: conditions,
+	}, nil
+}
+
+func (l *Logical) Allow(ctx context.Context, permit proxy.Permit) error {
+	if l.match(ctx, permit) != matchYes {
+		return proxy.ErrNoRulesetMatched
+	}
+	if l.isAllow {
+		return nil
+	}
+	return fmt.Errorf("logical: deny(%s): %s -> %s", l.op, permit.Source, permit.Destination)
+}
+
+// matchResult 条件的匹配结果；目标 IP 未知时，依赖目标 IP 的条件无法判断，
+// 整个规则视为不匹配，在解析后以 IP 地址再次检查时判断。
+type matchResult int
+
+const (
+	matchNo matchResult = iota
+	matchYes
+	matchUndecided
+)
+
+func (l *Logical) needsDestinationIP() bool {
+	return slices.ContainsFunc(l.conditions, NeedsDestinationIP)
+}
+
+func (l *Logical) match(ctx context.Context, permit proxy.Permit) matchResult {
+	switch l.op {
+	case LogicalAnd:
+		result := matchYes
+		for _, cond := range l.conditions {
+			switch matchCondition(ctx, cond, permit) {
+			case matchNo:
+				return matchNo
+			case matchUndecided:
+				result = matchUndecided
+			}
+		}
+		return result
+	case LogicalOr:
+		result := matchNo
+		for _, cond := range l.conditions {
+			switch matchCondition(ctx, cond, permit) {
+			case matchYes:
+				return matchYes
+			case matchUndecided:
+				result = matchUndecided
+			}
+		}
+		return result
+	default:
+		switch matchCondition(ctx, l.conditions[0], permit) {
+		case matchYes:
+			return matchNo
+		case matchNo:
+			return matchYes
+		default:
+			return matchUndecided
+		}
+	}
+}
+
+func matchCondition(ctx context.Context, cond proxy.Ruleset, permit proxy.Permit) matchResult {
+	if v, ok := cond.(*Logical); ok {
+		return v.match(ctx, permit)
+	}
+	if permit.Destination.IP == nil && NeedsDestinationIP(cond) {
+		return matchUndecided
+	}
+	if errors.Is(cond.Allow(ctx, permit), proxy.ErrNoRulesetMatched) {
+		return matchNo
+	}
+	return matchYes
+}
+
+// destinationIPRuleset 依赖目标 IP 地址判断的规则
+type destinationIPRuleset interface {
+	needsDestinationIP() bool
+}
+
+// NeedsDestinationIP 返回规则是否按目标 IP 地址匹配（包括条件中含此类规则的 logical 规则）
+func NeedsDestinationIP(r proxy.Ruleset) bool {
+	v, ok := r.(destinationIPRuleset)
+	return ok && v.needsDestinationIP()
+}
+
+// IsDomainRuleset 返回是否为按目标域名匹配的规则（domain 及域名规则列表）
+func IsDomainRuleset(r proxy.Ruleset) bool {
+	switch v := r.(type) {
+	case *Domain:
+		return !v.useSource
+	case *ListRuleset:
+		return IsDomainRuleset(*v.current.Load())
+	default:
+		return false
+	}
+}
+
+// IsDestinationRuleset 返回是否为仅按目标域名或 IP 地址匹配的规则，不依赖端口、用户、时间及来源地址
+func IsDestinationRuleset(r proxy.Ruleset) bool {
+	switch v := r.(type) {
+	case *ListRuleset:
+		return IsDestinationRuleset(*v.current.Load())
+	case *Logical:
+		return false
+	default:
+		return IsDomainRuleset(r) || NeedsDestinationIP(r)
+	}
+}
@@ -0,0 +1,146 @@
+package ruleset
+
+import (
+	"context"
+	stdnet "net"
+	"testing"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDomain(t *testing.T, patterns ...string) proxy.Ruleset {
+	matcher, err := NewDomainMatcher(patterns)
+	require.NoError(t, err)
+	return NewDomain(true, false, matcher)
+}
+
+func newTestIPNet(t *testing.T, useSource bool, cidrs ...string) proxy.Ruleset {
+	nets := make([]stdnet.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := stdnet.ParseCIDR(cidr)
+		require.NoError(t, err)
+		nets = append(nets, *ipNet)
+	}
+	return NewIPNet(true, useSource, nets)
+}
+
+// testDest 构建目标地址：仅域名（解析前）、域名及解析得到的 IP（解析后）或仅 IP
+func testDest(domain string, ip string) net.Address {
+	if ip == "" {
+		return net.ParseDomainAddr(net.NetworkTCP, domain)
+	}
+	addr := net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP(ip))
+	addr.Domain = domain
+	return addr
+}
+
+func TestLogicalAllow(t *testing.T) {
+	intranet := func(t *testing.T) proxy.Ruleset { return newTestIPNet(t, false, "10.0.0.0/8") }
+	corp := func(t *testing.T) proxy.Ruleset { return newTestDomain(t, "corp") }
+	lan := func(t *testing.T) proxy.Ruleset { return newTestIPNet(t, true, "192.168.0.0/16") }
+	tests := []struct {
+		name       string
+		op         string
+		conditions func(t *testing.T) []proxy.Ruleset
+		source     string
+		domain     string
+		ip         string
+		wantDeny   bool
+	}{
+		{"and: all match", LogicalAnd, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "a.corp", "10.1.1.1", true},
+		{"and: one not match", LogicalAnd, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "a.corp", "8.8.8.8", false},
+		{"and: ip unknown", LogicalAnd, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "a.corp", "", false},
+		{"and: decided without ip", LogicalAnd, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "example.com", "", false},
+		{"or: first match", LogicalOr, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "a.corp", "", true},
+		{"or: second match", LogicalOr, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "example.com", "10.1.1.1", true},
+		{"or: none match", LogicalOr, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "example.com", "8.8.8.8", false},
+		{"or: ip unknown", LogicalOr, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t), intranet(t)} }, "", "example.com", "", false},
+		{"not: ip unknown", LogicalNot, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{intranet(t)} }, "", "intranet.corp", "", false},
+		{"not: resolved inside", LogicalNot, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{intranet(t)} }, "", "intranet.corp", "10.1.1.1", false},
+		{"not: resolved outside", LogicalNot, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{intranet(t)} }, "", "example.com", "8.8.8.8", true},
+		{"not: ip destination", LogicalNot, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{intranet(t)} }, "", "", "8.8.8.8", true},
+		{"not: domain", LogicalNot, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{corp(t)} }, "", "example.com", "", true},
+		{"not: source ip known", LogicalNot, func(t *testing.T) []proxy.Ruleset { return []proxy.Ruleset{lan(t)} }, "10.0.0.1", "example.com", "", true},
+		{"nested: not(and)", LogicalNot, func(t *testing.T) []proxy.Ruleset {
+			and, err := NewLogical(true, LogicalAnd, []proxy.Ruleset{corp(t), intranet(t)})
+			require.NoError(t, err)
+			return []proxy.Ruleset{and}
+		}, "", "a.corp", "", false},
+		{"nested: not(and) resolved", LogicalNot, func(t *testing.T) []proxy.Ruleset {
+			and, err := NewLogical(true, LogicalAnd, []proxy.Ruleset{corp(t), intranet(t)})
+			require.NoError(t, err)
+			return []proxy.Ruleset{and}
+		}, "", "a.corp", "8.8.8.8", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLogical(false, tt.op, tt.conditions(t))
+			require.NoError(t, err)
+			permit := proxy.Permit{Destination: testDest(tt.domain, tt.ip)}
+			if tt.source != "" {
+				permit.Source = net.ParseIPAddr(net.NetworkTCP, stdnet.ParseIP(tt.source))
+			}
+			err = l.Allow(context.Background(), permit)
+			if tt.wantDeny {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			} else {
+				assert.ErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			}
+		})
+	}
+}
+
+func TestLogicalAllowAccess(t *testing.T) {
+	l, err := NewLogical(true, LogicalOr, []proxy.Ruleset{newTestDomain(t, "corp")})
+	require.NoError(t, err)
+	assert.NoError(t, l.Allow(context.Background(), proxy.Permit{Destination: testDest("a.corp", "")}))
+}
+
+func TestNewLogical(t *testing.T) {
+	cond := newTestDomain(t, "corp")
+	tests := []struct {
+		op         string
+		conditions []proxy.Ruleset
+		wantErr    bool
+	}{
+		{"AND", []proxy.Ruleset{cond}, false},
+		{LogicalOr, []proxy.Ruleset{cond, cond}, false},
+		{LogicalAnd, nil, true},
+		{LogicalOr, nil, true},
+		{LogicalNot, []proxy.Ruleset{cond}, false},
+		{LogicalNot, []proxy.Ruleset{cond, cond}, true},
+		{"xor", []proxy.Ruleset{cond}, true},
+	}
+	for _, tt := range tests {
+		_, err := NewLogical(true, tt.op, tt.conditions)
+		assert.Equal(t, tt.wantErr, err != nil, "op=%s conditions=%d", tt.op, len(tt.conditions))
+	}
+}
+
+func TestNeedsDestinationIP(t *testing.T) {
+	domainAndIP, err := NewLogical(false, LogicalAnd, []proxy.Ruleset{newTestDomain(t, "corp"), newTestIPNet(t, false, "10.0.0.0/8")})
+	require.NoError(t, err)
+	domainOnly, err := NewLogical(false, LogicalNot, []proxy.Ruleset{newTestDomain(t, "corp")})
+	require.NoError(t, err)
+	special, err := NewSpecialRanges(SpecialCategories())
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		ruleset proxy.Ruleset
+		want    bool
+	}{
+		{"domain", newTestDomain(t, "corp"), false},
+		{"destination ipnet", newTestIPNet(t, false, "10.0.0.0/8"), true},
+		{"source ipnet", newTestIPNet(t, true, "10.0.0.0/8"), false},
+		{"special ranges", special, true},
+		{"logical with ipnet", domainAndIP, true},
+		{"logical without ipnet", domainOnly, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NeedsDestinationIP(tt.ruleset), tt.name)
+	}
+}
@@ -0,0 +1,52 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+)
+
+var (
+	_ proxy.Ruleset = (*Port)(nil)
+)
+
+// Port 按端口及端口范围匹配的访问规则
+type Port struct {
+	useSource bool
+	isAllow   bool
+	ranges    []net.PortRange
+}
+
+func NewPort(isAllow bool, useSource bool, ranges []net.PortRange) *Port {
+	return &Port{
+		isAllow:   isAllow,
+		useSource: useSource,
+		ranges:    ranges,
+	}
+}
+
+func (p *Port) Allow(ctx context.Context, permit proxy.Permit) error {
+	var target net.Address
+	if p.useSource {
+		target = permit.Source
+	} else {
+		target = permit.Destination
+	}
+	if !p.match(target.Port) {
+		return proxy.ErrNoRulesetMatched
+	}
+	if p.isAllow {
+		return nil
+	}
+	return fmt.Errorf("port: deny: %s", target)
+}
+
+func (p *Port) match(port int) bool {
+	for _, r := range p.ranges {
+		if r.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"strings"
+	"time"
+)
+
+var (
+	_ proxy.Ruleset = (*Schedule)(nil)
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// TimeWindow 一天中的时间段，单位：分钟；From 大于 To 时表示跨越午夜
+type TimeWindow struct {
+	From int
+	To   int
+}
+
+// ParseTimeWindow 解析时间段，格式为 "HH:MM-HH:MM"，例如 "09:00-18:00"、"22:00-06:00"
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	sFrom, sTo, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %s", s)
+	}
+	from, err := parseClock(sFrom)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %s. %w", s, err)
+	}
+	to, err := parseClock(sTo)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %s. %w", s, err)
+	}
+	if from == to {
+		return TimeWindow{}, fmt.Errorf("invalid time window: %s: empty", s)
+	}
+	return TimeWindow{From: from, To: to}, nil
+}
+
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	// 24:00 表示一天的结束
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWeekdays 解析星期列表，支持单个星期及范围，例如 "mon-fri"、"sat"、"sun"；范围可跨越周末，例如 "fri-mon"
+func ParseWeekdays(items []string) ([7]bool, error) {
+	var days [7]bool
+	for _, item := range items {
+		sFrom, sTo, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(item)), "-")
+		from, ok := weekdayNames[sFrom]
+		if !ok {
+			return days, fmt.Errorf("invalid weekday: %s", item)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdayNames[sTo]; !ok {
+				return days, fmt.Errorf("invalid weekday: %s", item)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// Schedule 按星期及时间段匹配的访问规则，时间按指定时区计算。
+// 跨越午夜的时间段归属于其开始的那一天，例如周五 22:00-06:00 包含周六 00:00-06:00。
+type Schedule struct {
+	isAllow  bool
+	location *time.Location
+	weekdays [7]bool
+	windows  []TimeWindow
+	now      func() time.Time
+}
+
+// NewSchedule 创建时间规则；windows 为空时匹配全天
+func NewSchedule(isAllow bool, location *time.Location, weekdays [7]bool, windows []TimeWindow) *Schedule {
+	return &Schedule{
+		isAllow:  isAllow,
+		location: location,
+		weekdays: weekdays,
+		windows:  windows,
+		now:      time.Now,
+	}
+}
+
+func (s *Schedule) Allow(ctx context.Context, permit proxy.Permit) error {
+	now := s.now().In(s.location)
+	if !s.match(now) {
+		return proxy.ErrNoRulesetMatched
+	}
+	if s.isAllow {
+		return nil
+	}
+	return fmt.Errorf("schedule: deny: %s: %s", now.Format("Mon 15:04"), permit.Destination)
+}
+
+func (s *Schedule) match(t time.Time) bool {
+	day := t.Weekday()
+	if len(s.windows) == 0 {
+		return s.weekdays[day]
+	}
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := (day + 6) % 7
+	for _, w := range s.windows {
+		if w.From < w.To {
+			if s.weekdays[day] && minute >= w.From && minute < w.To {
+				return true
+			}
+		} else {
+			if (s.weekdays[day] && minute >= w.From) || (s.weekdays[yesterday] && minute < w.To) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package ruleset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSchedule(t *testing.T, loc *time.Location, weekdays []string, times []string) *Schedule {
+	days, err := ParseWeekdays(weekdays)
+	require.NoError(t, err)
+	windows := make([]TimeWindow, 0, len(times))
+	for _, s := range times {
+		w, err := ParseTimeWindow(s)
+		require.NoError(t, err)
+		windows = append(windows, w)
+	}
+	return NewSchedule(false, loc, days, windows)
+}
+
+func TestScheduleMatch(t *testing.T) {
+	at := func(date, clock string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.UTC)
+		require.NoError(t, err)
+		return v
+	}
+	// 2024-06-07 为星期五
+	const (
+		thu = "2024-06-06"
+		fri = "2024-06-07"
+		sat = "2024-06-08"
+		sun = "2024-06-09"
+		mon = "2024-06-10"
+		tue = "2024-06-11"
+	)
+	tests := []struct {
+		name     string
+		weekdays []string
+		times    []string
+		now      time.Time
+		want     bool
+	}{
+		// 22:00-06:00 跨越午夜，归属于开始的那一天
+		{"overnight: start of window", []string{"fri"}, []string{"22:00-06:00"}, at(fri, "22:00"), true},
+		{"overnight: before window", []string{"fri"}, []string{"22:00-06:00"}, at(fri, "21:59"), false},
+		{"overnight: midnight next day", []string{"fri"}, []string{"22:00-06:00"}, at(sat, "00:00"), true},
+		{"overnight: end exclusive", []string{"fri"}, []string{"22:00-06:00"}, at(sat, "06:00"), false},
+		{"overnight: before end", []string{"fri"}, []string{"22:00-06:00"}, at(sat, "05:59"), true},
+		{"overnight: morning of start day", []string{"fri"}, []string{"22:00-06:00"}, at(fri, "05:00"), false},
+		{"overnight: evening of next day", []string{"fri"}, []string{"22:00-06:00"}, at(sat, "23:00"), false},
+		{"overnight: sat to sun", []string{"sat"}, []string{"22:00-06:00"}, at(sun, "01:00"), true},
+		{"overnight: sun to mon", []string{"sun"}, []string{"22:00-06:00"}, at(mon, "03:00"), true},
+		// fri-mon 跨越周末
+		{"wrap: fri", []string{"fri-mon"}, nil, at(fri, "12:00"), true},
+		{"wrap: sun", []string{"fri-mon"}, nil, at(sun, "12:00"), true},
+		{"wrap: mon", []string{"fri-mon"}, nil, at(mon, "23:59"), true},
+		{"wrap: tue", []string{"fri-mon"}, nil, at(tue, "00:00"), false},
+		{"wrap: thu", []string{"fri-mon"}, nil, at(thu, "23:59"), false},
+		{"wrap with window", []string{"fri-mon"}, []string{"09:00-18:00"}, at(sun, "09:00"), true},
+		{"wrap with overnight window", []string{"fri-mon"}, []string{"22:00-06:00"}, at(tue, "05:00"), true},
+		{"wrap with overnight window end", []string{"fri-mon"}, []string{"22:00-06:00"}, at(tue, "22:00"), false},
+		// xx:xx-24:00 至当天结束
+		{"until 24:00: start", []string{"fri"}, []string{"18:00-24:00"}, at(fri, "18:00"), true},
+		{"until 24:00: last minute", []string{"fri"}, []string{"18:00-24:00"}, at(fri, "23:59"), true},
+		{"until 24:00: next day midnight", []string{"fri"}, []string{"18:00-24:00"}, at(sat, "00:00"), false},
+		{"until 24:00: before", []string{"fri"}, []string{"18:00-24:00"}, at(fri, "17:59"), false},
+		{"whole day", []string{"fri"}, []string{"00:00-24:00"}, at(fri, "00:00"), true},
+		{"until 00:00", []string{"fri"}, []string{"22:00-00:00"}, at(sat, "00:00"), false},
+		{"until 00:00: last minute", []string{"fri"}, []string{"22:00-00:00"}, at(fri, "23:59"), true},
+		{"multiple windows", []string{"mon-fri"}, []string{"09:00-12:00", "13:00-18:00"}, at(thu, "12:30"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSchedule(t, time.UTC, tt.weekdays, tt.times)
+			s.now = func() time.Time { return tt.now }
+			err := s.Allow(context.Background(), proxy.Permit{})
+			if tt.want {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			} else {
+				assert.ErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			}
+		})
+	}
+}
+
+func TestScheduleLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	s := newTestSchedule(t, loc, []string{"sat"}, []string{"00:00-06:00"})
+	// 周五 UTC 20:00 即周六 UTC+8 04:00
+	s.now = func() time.Time { return time.Date(2024, 6, 7, 20, 0, 0, 0, time.UTC) }
+	assert.Error(t, s.Allow(context.Background(), proxy.Permit{}))
+	s.now = func() time.Time { return time.Date(2024, 6, 7, 23, 0, 0, 0, time.UTC) }
+	assert.ErrorIs(t, s.Allow(context.Background(), proxy.Permit{}), proxy.ErrNoRulesetMatched)
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    TimeWindow
+		wantErr bool
+	}{
+		{"09:00-18:00", TimeWindow{From: 540, To: 1080}, false},
+		{"22:00-06:00", TimeWindow{From: 1320, To: 360}, false},
+		{"18:00-24:00", TimeWindow{From: 1080, To: 1440}, false},
+		{" 00:00 - 24:00 ", TimeWindow{From: 0, To: 1440}, false},
+		{"09:00-09:00", TimeWindow{}, true},
+		{"24:00-24:00", TimeWindow{}, true},
+		{"25:00-06:00", TimeWindow{}, true},
+		{"09:00", TimeWindow{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeWindow(tt.s)
+		if tt.wantErr {
+			assert.Error(t, err, tt.s)
+			continue
+		}
+		require.NoError(t, err, tt.s)
+		assert.Equal(t, tt.want, got, tt.s)
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		items   []string
+		want    [7]bool
+		wantErr bool
+	}{
+		{[]string{"mon-fri"}, [7]bool{false, true, true, true, true, true, false}, false},
+		{[]string{"fri-mon"}, [7]bool{true, true, false, false, false, true, true}, false},
+		{[]string{"sat", "SUN"}, [7]bool{true, false, false, false, false, false, true}, false},
+		{[]string{"sun-sat"}, [7]bool{true, true, true, true, true, true, true}, false},
+		{[]string{"wed-wed"}, [7]bool{false, false, false, true, false, false, false}, false},
+		{[]string{"monday"}, [7]bool{}, true},
+		{[]string{"mon-xyz"}, [7]bool{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWeekdays(tt.items)
+		if tt.wantErr {
+			assert.Error(t, err, tt.items)
+			continue
+		}
+		require.NoError(t, err, tt.items)
+		assert.Equal(t, tt.want, got, tt.items)
+	}
+}
@@ -0,0 +1,125 @@
+package ruleset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	stdnet "net"
+	"slices"
+)
+
+var (
+	_ proxy.Ruleset = (*SpecialRanges)(nil)
+)
+
+var (
+	ErrSpecialRange = errors.New("special-range: deny")
+)
+
+// special range category
+const (
+	SpecialLoopback    = "loopback"
+	SpecialPrivate     = "private"
+	SpecialLinkLocal   = "link-local"
+	SpecialMetadata    = "metadata"
+	SpecialULA         = "ula"
+	SpecialCGNAT       = "cgnat"
+	SpecialUnspecified = "unspecified"
+	SpecialMulticast   = "multicast"
+	SpecialReserved    = "reserved"
+)
+
+var specialRanges = map[string][]string{
+	SpecialLoopback:    {"127.0.0.0/8", "::1/128"},
+	SpecialPrivate:     {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+	SpecialLinkLocal:   {"169.254.0.0/16", "fe80::/10"},
+	SpecialMetadata:    {"169.254.169.254/32", "169.254.170.2/32", "100.100.100.200/32", "fd00:ec2::254/128"},
+	SpecialULA:         {"fc00::/7"},
+	SpecialCGNAT:       {"100.64.0.0/10"},
+	SpecialUnspecified: {"0.0.0.0/8", "::/128"},
+	SpecialMulticast:   {"224.0.0.0/4", "ff00::/8"},
+	SpecialReserved:    {"240.0.0.0/4", "192.0.0.0/24", "198.18.0.0/15", "64:ff9b::/96", "64:ff9b:1::/48", "2002::/16", "2001:db8::/32"},
+}
+
+var (
+	nat64Prefix = stdnet.IPNet{IP: stdnet.ParseIP("64:ff9b::"), Mask: stdnet.CIDRMask(96, 128)}
+	sixToFour   = stdnet.IPNet{IP: stdnet.ParseIP("2002::"), Mask: stdnet.CIDRMask(16, 128)}
+)
+
+// SpecialCategories 返回全部内置的特殊地址类别名称
+func SpecialCategories() []string {
+	names := make([]string, 0, len(specialRanges))
+	for name := range specialRanges {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+type specialNet struct {
+	category string
+	net      *stdnet.IPNet
+}
+
+// SpecialRanges 禁止访问内置类别的特殊地址（私有网络、链路本地、云元数据等），
+// 仅检查 IP 地址形式的目标；域名目标在解析后由 Dispatcher 再次检查，由上游代理远程解析的域名不检查。
+// NAT64 及 6to4 地址同时检查其内嵌的 IPv4 地址；IPv4 映射地址（::ffff:0:0/96）按 IPv4 地址匹配。
+type SpecialRanges struct {
+	nets []specialNet
+}
+
+func NewSpecialRanges(categories []string) (*SpecialRanges, error) {
+	nets := make([]specialNet, 0, len(categories)*2)
+	for _, category := range categories {
+		cidrs, ok := specialRanges[category]
+		if !ok {
+			return nil, fmt.Errorf("special-range: unknown category: %s", category)
+		}
+		for _, cidr := range cidrs {
+			_, ipNet, err := stdnet.ParseCIDR(cidr)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, specialNet{category: category, net: ipNet})
+		}
+	}
+	return &SpecialRanges{nets: nets}, nil
+}
+
+func (s *SpecialRanges) Allow(ctx context.Context, permit proxy.Permit) error {
+	target := permit.Destination
+	if target.IsDomain() || target.IP == nil {
+		return proxy.ErrNoRulesetMatched
+	}
+	ips := []stdnet.IP{target.IP}
+	if embedded := embeddedIPv4(target.IP); embedded != nil {
+		ips = append(ips, embedded)
+	}
+	for _, n := range s.nets {
+		for _, ip := range ips {
+			if n.net.Contains(ip) {
+				return fmt.Errorf("%w: %s: %s", ErrSpecialRange, n.category, target)
+			}
+		}
+	}
+	return proxy.ErrNoRulesetMatched
+}
+
+func (s *SpecialRanges) needsDestinationIP() bool {
+	return true
+}
+
+// embeddedIPv4 返回 NAT64（64:ff9b::/96）及 6to4（2002::/16）地址中内嵌的 IPv4 地址
+func embeddedIPv4(ip stdnet.IP) stdnet.IP {
+	if ip.To4() != nil || len(ip) != stdnet.IPv6len {
+		return nil
+	}
+	switch {
+	case nat64Prefix.Contains(ip):
+		return stdnet.IPv4(ip[12], ip[13], ip[14], ip[15])
+	case sixToFour.Contains(ip):
+		return stdnet.IPv4(ip[2], ip[3], ip[4], ip[5])
+	}
+	return nil
+}
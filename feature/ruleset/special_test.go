@@ -0,0 +1,64 @@
+package ruleset
+
+import (
+	"context"
+	stdnet "net"
+	"testing"
+
+	"github.com/fluxproxy/fluxproxy"
+	"github.com/fluxproxy/fluxproxy/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecialRangesAllow(t *testing.T) {
+	tests := []struct {
+		name       string
+		categories []string
+		target     string
+		wantDeny   bool
+	}{
+		{"public ipv4", nil, "8.8.8.8", false},
+		{"public ipv6", nil, "2606:4700::1111", false},
+		{"loopback", []string{SpecialLoopback}, "127.0.0.1", true},
+		{"mapped loopback", []string{SpecialLoopback}, "::ffff:127.0.0.1", true},
+		{"mapped private", []string{SpecialPrivate}, "::ffff:10.1.2.3", true},
+		{"nat64 metadata", []string{SpecialMetadata}, "64:ff9b::a9fe:a9fe", true},
+		{"nat64 private", []string{SpecialPrivate}, "64:ff9b::c0a8:0101", true},
+		{"nat64 public", []string{SpecialPrivate}, "64:ff9b::808:808", false},
+		{"nat64 reserved", []string{SpecialReserved}, "64:ff9b::808:808", true},
+		{"6to4 loopback", []string{SpecialLoopback}, "2002:7f00:1::1", true},
+		{"6to4 metadata", []string{SpecialMetadata}, "2002:a9fe:a9fe::", true},
+		{"6to4 public", []string{SpecialPrivate}, "2002:808:808::1", false},
+		{"6to4 reserved", []string{SpecialReserved}, "2002:808:808::1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categories := tt.categories
+			if categories == nil {
+				categories = SpecialCategories()
+			}
+			s, err := NewSpecialRanges(categories)
+			require.NoError(t, err)
+			ip := stdnet.ParseIP(tt.target)
+			require.NotNil(t, ip)
+			err = s.Allow(context.Background(), proxy.Permit{
+				Destination: net.ParseIPAddr(net.NetworkTCP, ip),
+			})
+			if tt.wantDeny {
+				assert.ErrorIs(t, err, ErrSpecialRange)
+			} else {
+				assert.ErrorIs(t, err, proxy.ErrNoRulesetMatched)
+			}
+		})
+	}
+}
+
+func TestSpecialRangesDomain(t *testing.T) {
+	s, err := NewSpecialRanges(SpecialCategories())
+	require.NoError(t, err)
+	err = s.Allow(context.Background(), proxy.Permit{
+		Destination: net.ParseDomainAddr(net.NetworkTCP, "localhost"),
+	})
+	assert.ErrorIs(t, err, proxy.ErrNoRulesetMatched)
+}
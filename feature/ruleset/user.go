@@ -0,0 +1,52 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"github.com/fluxproxy/fluxproxy"
+	"slices"
+)
+
+var (
+	_ proxy.Ruleset = (*User)(nil)
+)
+
+// User 按通过身份认证的用户名及用户组匹配的访问规则；未认证的连接不匹配
+type User struct {
+	isAllow bool
+	users   []string
+	groups  []string
+}
+
+func NewUser(isAllow bool, users []string, groups []string) *User {
+	return &User{
+		isAllow: isAllow,
+		users:   users,
+		groups:  groups,
+	}
+}
+
+func (u *User) Allow(ctx context.Context, permit proxy.Permit) error {
+	if !u.match(permit.Principal) {
+		return proxy.ErrNoRulesetMatched
+	}
+	if u.isAllow {
+		return nil
+	}
+	return fmt.Errorf("user: deny: %s: %s", permit.Principal.Name, permit.Destination)
+}
+
+func (u *User) match(principal proxy.Principal) bool {
+	if principal.Name == "" {
+		return false
+	}
+	if slices.Contains(u.users, principal.Name) {
+		return true
+	}
+	for _, group := range principal.Groups {
+		if slices.Contains(u.groups, group) {
+			return true
+		}
+	}
+	return false
+}
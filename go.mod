@@ -6,17 +6,19 @@ require (
 	github.com/bytepowered/assert v1.1.0
 	github.com/bytepowered/cache v0.3.0
 	github.com/cristalhq/acmd v0.12.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/knadh/koanf/parsers/toml v0.1.0
 	github.com/knadh/koanf/providers/file v1.0.0
 	github.com/knadh/koanf/v2 v2.1.1
 	github.com/lithammer/shortuuid/v4 v4.0.0
+	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/sirupsen/logrus v1.9.3
-	github.com/stretchr/testify v1.8.1
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/net v0.26.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.0.0-alpha.1 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/knadh/koanf/maps v0.1.1 // indirect
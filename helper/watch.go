@@ -0,0 +1,68 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"time"
+)
+
+const (
+	watchDebounce = time.Millisecond * 500
+)
+
+// WatchFiles 监听文件变更（包括以重命名方式原子替换文件），变更后回调 onChange；
+// 监听随 ctx 结束而停止。
+func WatchFiles(ctx context.Context, files []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch: new watcher. %w", err)
+	}
+	targets := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("watch: abs path: %s. %w", file, err)
+		}
+		targets[abs] = struct{}{}
+		// 监听所在目录，以兼容编辑器/部署工具的替换写入方式
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("watch: add: %s. %w", file, err)
+		}
+	}
+	go func() {
+		defer func() {
+			_ = watcher.Close()
+		}()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if _, hit := targets[filepath.Clean(event.Name)]; !hit {
+					continue
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+					debounce = time.After(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logrus.Warnf("watch: error: %s", err)
+			case <-debounce:
+				debounce = nil
+				onChange()
+			}
+		}
+	}()
+	return nil
+}
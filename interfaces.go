@@ -25,6 +25,12 @@ type Authentication struct {
 	Authentication string       // 用于身份验证的凭证
 }
 
+// Principal 通过身份认证的用户身份；Name 为空表示未认证
+type Principal struct {
+	Name   string   // 用户名
+	Groups []string // 用户所属的用户组
+}
+
 // Listener 监听器，监听服务端口，完成与客户端的连接握手。
 type Listener interface {
 	// Listen 以阻塞态监听服务端，接收客户端连接
@@ -33,8 +39,8 @@ type Listener interface {
 
 // Dispatcher 管理通道连接请求及路由
 type Dispatcher interface {
-	// Authenticate 对客户端进行身份认证
-	Authenticate(ctx context.Context, auth Authentication) error
+	// Authenticate 对客户端进行身份认证，返回通过认证的用户身份
+	Authenticate(ctx context.Context, auth Authentication) (Principal, error)
 
 	// Dispatch 执行通道连接（同步执行）
 	Dispatch(Connector)
@@ -75,20 +81,27 @@ type Dialer interface {
 	Dial(ctx context.Context, remote net.Address) (Connection, error)
 }
 
+// RemoteResolver 可由远端解析域名的 Dialer，启用时 Dispatcher 不在本地解析目标域名
+type RemoteResolver interface {
+	// RemoteResolve 返回是否将域名原样交由远端解析
+	RemoteResolve() bool
+}
+
 // Resolver 域名解析器
 type Resolver interface {
-	// Resolve 将域名解析成 IP 地址
-	Resolve(ctx context.Context, addr net.Address) (stdnet.IP, error)
+	// Resolve 将域名解析成 IP 地址，返回按优先顺序排列的全部地址
+	Resolve(ctx context.Context, addr net.Address) ([]stdnet.IP, error)
 }
 
 // Authenticator 身份认证
 type Authenticator interface {
-	Authenticate(context.Context, Authentication) error
+	Authenticate(context.Context, Authentication) (Principal, error)
 }
 
 type Permit struct {
 	Source      net.Address
 	Destination net.Address
+	Principal   Principal
 }
 
 type Ruleset interface {
@@ -10,6 +10,8 @@ import (
 
 var (
 	CtxKeyStartTime = "ctx-key:start-time"
+	// CtxKeyOutbound 连接选定的出站 Dialer，用于经由出站解析域名
+	CtxKeyOutbound = "ctx-key:outbound"
 )
 
 func SetupTcpContextLogger(ctx context.Context, conn net.Conn) context.Context {
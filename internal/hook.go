@@ -10,10 +10,11 @@ type hookCtxKey struct {
 }
 
 var (
-	CtxHookAfterResolve = hookCtxKey{key: "ctx:hook-func:after-resolve"}
-	CtxHookAfterDial    = hookCtxKey{key: "ctx:hook-func:after-dial"}
-	CtxHookAfterRuleset = hookCtxKey{key: "ctx:hook-func:after-ruleset"}
-	CtxHookAfterConnect = hookCtxKey{key: "ctx:hook-func:after-connect"}
+	CtxHookAfterResolve         = hookCtxKey{key: "ctx:hook-func:after-resolve"}
+	CtxHookAfterDial            = hookCtxKey{key: "ctx:hook-func:after-dial"}
+	CtxHookAfterRuleset         = hookCtxKey{key: "ctx:hook-func:after-ruleset"}
+	CtxHookAfterResolvedRuleset = hookCtxKey{key: "ctx:hook-func:after-resolved-ruleset"}
+	CtxHookAfterConnect         = hookCtxKey{key: "ctx:hook-func:after-connect"}
 )
 
 func ContextWithHooks(ctx context.Context, hooks map[any]proxy.HookFunc) context.Context {
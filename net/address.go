@@ -135,6 +135,9 @@ func ParseAddress(network Network, hostport string) (Address, error) {
 		}, nil
 	}
 	// IPv4 / IPv6
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
 	switch len(ip) {
 	case net.IPv4len:
 		return Address{
@@ -0,0 +1,77 @@
+package net
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+)
+
+// CIDRSet 网段集合，按前缀长度分组索引，查询开销与网段数量无关，适用于大量网段的匹配
+type CIDRSet struct {
+	prefixes map[netip.Prefix]struct{}
+	// 已添加的前缀长度，分别对应 IPv4 与 IPv6
+	bits4 []int
+	bits6 []int
+}
+
+func NewCIDRSet() *CIDRSet {
+	return &CIDRSet{prefixes: make(map[netip.Prefix]struct{})}
+}
+
+// Add 添加网段；无效的网段返回 false
+func (s *CIDRSet) Add(ipNet net.IPNet) bool {
+	addr, ok := netip.AddrFromSlice(ipNet.IP)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	ones, bits := ipNet.Mask.Size()
+	if bits == 0 {
+		return false
+	}
+	// IPv4 网段以 IPv6 掩码形式表示时，换算为 IPv4 前缀长度
+	if addr.Is4() && bits == 128 {
+		ones -= 96
+	}
+	prefix, err := addr.Prefix(ones)
+	if err != nil {
+		return false
+	}
+	s.prefixes[prefix] = struct{}{}
+	if addr.Is4() {
+		s.bits4 = insertBits(s.bits4, ones)
+	} else {
+		s.bits6 = insertBits(s.bits6, ones)
+	}
+	return true
+}
+
+func (s *CIDRSet) Contains(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	bits := s.bits6
+	if addr.Is4() {
+		bits = s.bits4
+	}
+	for _, ones := range bits {
+		prefix, _ := addr.Prefix(ones)
+		if _, hit := s.prefixes[prefix]; hit {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *CIDRSet) Size() int {
+	return len(s.prefixes)
+}
+
+func insertBits(bits []int, ones int) []int {
+	if i, found := slices.BinarySearch(bits, ones); !found {
+		return slices.Insert(bits, i, ones)
+	}
+	return bits
+}
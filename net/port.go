@@ -0,0 +1,55 @@
+package net
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortRange 端口范围，From 与 To 均包含在范围内
+type PortRange struct {
+	From int
+	To   int
+}
+
+func (r PortRange) Contains(port int) bool {
+	return port >= r.From && port <= r.To
+}
+
+func (r PortRange) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(r.From)
+	}
+	return strconv.Itoa(r.From) + "-" + strconv.Itoa(r.To)
+}
+
+// ParsePortRange 解析 "443" 或 "8000-9000" 形式的端口范围
+func ParsePortRange(s string) (PortRange, error) {
+	sFrom, sTo, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	from, err := parsePort(sFrom)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range: %s. %w", s, err)
+	}
+	if !isRange {
+		return PortRange{From: from, To: from}, nil
+	}
+	to, err := parsePort(sTo)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range: %s. %w", s, err)
+	}
+	if from > to {
+		return PortRange{}, fmt.Errorf("invalid port range: %s", s)
+	}
+	return PortRange{From: from, To: to}, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if port < 0 || port > 65535 {
+		return 0, fmt.Errorf("port out of range: %d", port)
+	}
+	return port, nil
+}
@@ -0,0 +1,69 @@
+package net
+
+import (
+	"strings"
+)
+
+// DomainTrie 以域名标签（从顶级域开始）构建的前缀树，用于大量域名后缀的快速匹配
+type DomainTrie struct {
+	root *domainNode
+	size int
+}
+
+type domainNode struct {
+	children map[string]*domainNode
+	// terminal 表示从根到此节点构成一个完整的后缀
+	terminal bool
+}
+
+func NewDomainTrie() *DomainTrie {
+	return &DomainTrie{root: &domainNode{}}
+}
+
+// Insert 添加域名后缀，匹配该域名自身及其全部子域名
+func (t *DomainTrie) Insert(suffix string) {
+	labels := strings.Split(NormalizeDomain(suffix), ".")
+	node := t.root
+	for i := len(labels) - 1; i >= 0; i-- {
+		if node.children == nil {
+			node.children = make(map[string]*domainNode)
+		}
+		next, ok := node.children[labels[i]]
+		if !ok {
+			next = &domainNode{}
+			node.children[labels[i]] = next
+		}
+		node = next
+	}
+	if !node.terminal {
+		node.terminal = true
+		t.size++
+	}
+}
+
+// Match 返回域名是否为已添加的某个后缀本身或其子域名
+func (t *DomainTrie) Match(domain string) bool {
+	domain = NormalizeDomain(domain)
+	node := t.root
+	for end := len(domain); end >= 0; {
+		start := strings.LastIndexByte(domain[:end], '.')
+		next, ok := node.children[domain[start+1:end]]
+		if !ok {
+			return false
+		}
+		if next.terminal {
+			return true
+		}
+		node, end = next, start
+	}
+	return false
+}
+
+func (t *DomainTrie) Size() int {
+	return t.size
+}
+
+// NormalizeDomain 转换为小写，并去除末尾的根域名 "."
+func NormalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
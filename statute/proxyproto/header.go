@@ -0,0 +1,219 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// protocol version
+const (
+	Version1 = byte(0x01)
+	Version2 = byte(0x02)
+)
+
+// command defined
+const (
+	CommandLocal = byte(0x00)
+	CommandProxy = byte(0x01)
+)
+
+// v2 address family and transport protocol
+const (
+	familyUnspec = byte(0x00)
+	familyInet   = byte(0x10)
+	familyInet6  = byte(0x20)
+	familyUnix   = byte(0x30)
+	protoStream  = byte(0x01)
+)
+
+// maxV1HeaderLen is the max length of v1 header, including the CRLF
+const maxV1HeaderLen = 107
+
+var (
+	signatureV1 = []byte("PROXY ")
+	signatureV2 = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+)
+
+// error defined
+var (
+	ErrNoProxyProtocol   = errors.New("proxyproto: no proxy protocol header")
+	ErrInvalidHeader     = errors.New("proxyproto: invalid header")
+	ErrNotSupportVersion = errors.New("proxyproto: not support version")
+)
+
+// Header is the PROXY protocol header
+// The v1 header is formed as follows:
+//
+//	PROXY TCP4 <src-ip> <dst-ip> <src-port> <dst-port>\r\n
+//	PROXY UNKNOWN\r\n
+//
+// The v2 header is formed as follows:
+//
+//	+-----------+---------+---------+-----+-----------+-------+
+//	| SIGNATURE | VER/CMD | FAM/PRO | LEN | ADDRESSES | TLVS  |
+//	+-----------+---------+---------+-----+-----------+-------+
+//	|    12     |    1    |    1    |  2  | Variable  | Var.  |
+//	+-----------+---------+---------+-----+-----------+-------+
+type Header struct {
+	Version byte
+	Command byte
+	// Source and Destination are nil for LOCAL command and UNKNOWN protocol
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+}
+
+// ReadHeader reads v1 or v2 header from io.Reader, never reading beyond the header
+func ReadHeader(r io.Reader) (h Header, err error) {
+	tmp := []byte{0}
+	if _, err = io.ReadFull(r, tmp); err != nil {
+		return h, fmt.Errorf("failed to get header, %w", err)
+	}
+	switch tmp[0] {
+	case signatureV1[0]:
+		return readV1(r)
+	case signatureV2[0]:
+		return readV2(r)
+	default:
+		return h, ErrNoProxyProtocol
+	}
+}
+
+func readV1(r io.Reader) (h Header, err error) {
+	line := make([]byte, 1, maxV1HeaderLen)
+	line[0] = signatureV1[0]
+	tmp := []byte{0}
+	for {
+		if len(line) >= maxV1HeaderLen {
+			return h, fmt.Errorf("%w: v1 header too long", ErrInvalidHeader)
+		}
+		if _, err = io.ReadFull(r, tmp); err != nil {
+			return h, fmt.Errorf("failed to get v1 header, %w", err)
+		}
+		line = append(line, tmp[0])
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasPrefix(line, signatureV1) {
+		return h, ErrNoProxyProtocol
+	}
+	h.Version, h.Command = Version1, CommandProxy
+	fields := strings.Split(string(line[len(signatureV1):len(line)-2]), " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		return h, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 5 {
+			return h, fmt.Errorf("%w: v1 fields", ErrInvalidHeader)
+		}
+		if h.Source, err = parseV1Addr(fields[0], fields[1], fields[3]); err != nil {
+			return h, err
+		}
+		if h.Destination, err = parseV1Addr(fields[0], fields[2], fields[4]); err != nil {
+			return h, err
+		}
+		return h, nil
+	default:
+		return h, fmt.Errorf("%w: v1 protocol: %s", ErrInvalidHeader, fields[0])
+	}
+}
+
+func parseV1Addr(proto, host, sport string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (proto == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("%w: v1 address: %s", ErrInvalidHeader, host)
+	}
+	port, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: v1 port: %s", ErrInvalidHeader, sport)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readV2(r io.Reader) (h Header, err error) {
+	head := make([]byte, 16)
+	head[0] = signatureV2[0]
+	if _, err = io.ReadFull(r, head[1:]); err != nil {
+		return h, fmt.Errorf("failed to get v2 header, %w", err)
+	}
+	if !bytes.Equal(head[:12], signatureV2) {
+		return h, ErrNoProxyProtocol
+	}
+	if head[12]>>4 != Version2 {
+		return h, ErrNotSupportVersion
+	}
+	h.Version, h.Command = Version2, head[12]&0x0F
+	if h.Command != CommandLocal && h.Command != CommandProxy {
+		return h, fmt.Errorf("%w: v2 command: %d", ErrInvalidHeader, h.Command)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return h, fmt.Errorf("failed to get v2 addresses, %w", err)
+	}
+	if h.Command == CommandLocal {
+		return h, nil
+	}
+	family, proto := head[13]&0xF0, head[13]&0x0F
+	if proto != protoStream {
+		// Only stream (TCP) connections carry a usable source address
+		return h, nil
+	}
+	switch family {
+	case familyInet:
+		if len(payload) < 12 {
+			return h, fmt.Errorf("%w: v2 inet addresses", ErrInvalidHeader)
+		}
+		h.Source = &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		h.Destination = &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+	case familyInet6:
+		if len(payload) < 36 {
+			return h, fmt.Errorf("%w: v2 inet6 addresses", ErrInvalidHeader)
+		}
+		h.Source = &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		h.Destination = &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+	case familyUnspec, familyUnix:
+		// ignore
+	default:
+		return h, fmt.Errorf("%w: v2 family: %d", ErrInvalidHeader, family)
+	}
+	return h, nil
+}
+
+// Bytes returns the header in its version format
+func (h Header) Bytes() []byte {
+	if h.Version == Version1 {
+		if h.Source == nil || h.Destination == nil {
+			return []byte("PROXY UNKNOWN\r\n")
+		}
+		proto := "TCP6"
+		if h.Source.IP.To4() != nil {
+			proto = "TCP4"
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n",
+			proto, h.Source.IP, h.Destination.IP, h.Source.Port, h.Destination.Port))
+	}
+	b := make([]byte, 0, 16+36)
+	b = append(b, signatureV2...)
+	b = append(b, Version2<<4|h.Command)
+	if h.Command == CommandLocal || h.Source == nil || h.Destination == nil {
+		return append(b, familyUnspec, 0, 0)
+	}
+	if src4, dst4 := h.Source.IP.To4(), h.Destination.IP.To4(); src4 != nil && dst4 != nil {
+		b = append(b, familyInet|protoStream, 0, 12)
+		b = append(b, src4...)
+		b = append(b, dst4...)
+	} else {
+		b = append(b, familyInet6|protoStream, 0, 36)
+		b = append(b, h.Source.IP.To16()...)
+		b = append(b, h.Destination.IP.To16()...)
+	}
+	b = append(b, byte(h.Source.Port>>8), byte(h.Source.Port))
+	b = append(b, byte(h.Destination.Port>>8), byte(h.Destination.Port))
+	return b
+}
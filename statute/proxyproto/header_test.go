@@ -0,0 +1,123 @@
+package proxyproto
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Header
+		wantErr error
+	}{
+		{
+			"V1 TCP4",
+			[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"),
+			Header{
+				Version1, CommandProxy,
+				&net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 56324},
+				&net.TCPAddr{IP: net.ParseIP("192.168.0.11"), Port: 443},
+			},
+			nil,
+		},
+		{
+			"V1 TCP6",
+			[]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			Header{
+				Version1, CommandProxy,
+				&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324},
+				&net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+			},
+			nil,
+		},
+		{
+			"V1 UNKNOWN",
+			[]byte("PROXY UNKNOWN\r\n"),
+			Header{Version: Version1, Command: CommandProxy},
+			nil,
+		},
+		{
+			"V1 mismatched family",
+			[]byte("PROXY TCP4 2001:db8::1 192.168.0.11 56324 443\r\n"),
+			Header{},
+			ErrInvalidHeader,
+		},
+		{
+			"V2 TCP4",
+			append(append([]byte{}, signatureV2...), 0x21, 0x11, 0, 12, 192, 168, 0, 1, 192, 168, 0, 11, 0xdc, 0x04, 0x01, 0xbb),
+			Header{
+				Version2, CommandProxy,
+				&net.TCPAddr{IP: net.IP{192, 168, 0, 1}, Port: 56324},
+				&net.TCPAddr{IP: net.IP{192, 168, 0, 11}, Port: 443},
+			},
+			nil,
+		},
+		{
+			"V2 LOCAL",
+			append(append([]byte{}, signatureV2...), 0x20, 0x00, 0, 0),
+			Header{Version: Version2, Command: CommandLocal},
+			nil,
+		},
+		{
+			"V2 invalid version",
+			append(append([]byte{}, signatureV2...), 0x11, 0x11, 0, 0),
+			Header{},
+			ErrNotSupportVersion,
+		},
+		{
+			"No header",
+			[]byte("GET / HTTP/1.1\r\n"),
+			Header{},
+			ErrNoProxyProtocol,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadHeader(bytes.NewReader(tt.data))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Version, got.Version)
+			assert.Equal(t, tt.want.Command, got.Command)
+			if tt.want.Source == nil {
+				assert.Nil(t, got.Source)
+				assert.Nil(t, got.Destination)
+				return
+			}
+			assert.True(t, tt.want.Source.IP.Equal(got.Source.IP))
+			assert.Equal(t, tt.want.Source.Port, got.Source.Port)
+			assert.True(t, tt.want.Destination.IP.Equal(got.Destination.IP))
+			assert.Equal(t, tt.want.Destination.Port, got.Destination.Port)
+		})
+	}
+}
+
+func TestReadHeaderNoOverRead(t *testing.T) {
+	data := append([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"), "payload"...)
+	r := bytes.NewReader(data)
+	_, err := ReadHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, 7, r.Len())
+}
+
+func TestHeaderBytes(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 443}
+	for _, version := range []byte{Version1, Version2} {
+		h := Header{Version: version, Command: CommandProxy, Source: src, Destination: dst}
+		got, err := ReadHeader(bytes.NewReader(h.Bytes()))
+		require.NoError(t, err)
+		assert.True(t, src.IP.Equal(got.Source.IP))
+		assert.Equal(t, src.Port, got.Source.Port)
+		assert.True(t, dst.IP.Equal(got.Destination.IP))
+		assert.Equal(t, dst.Port, got.Destination.Port)
+	}
+}
@@ -0,0 +1,169 @@
+package socks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// VersionSocks4 socks4 protocol version
+const VersionSocks4 = byte(0x04)
+
+// socks4 reply version and status
+const (
+	Reply4Version         = byte(0x00)
+	Rep4Granted           = byte(90)
+	Rep4Rejected          = byte(91)
+	Rep4IdentdUnreachable = byte(92)
+	Rep4IdentdMismatch    = byte(93)
+)
+
+// maxSocks4FieldLen limits the length of USERID and DOMAIN fields
+const maxSocks4FieldLen = 255
+
+// error defined
+var (
+	ErrSocks4FieldTooLong = errors.New("socks4 field too long")
+)
+
+// Request4 represents the SOCKS4/SOCKS4a request
+// The SOCKS4 request is formed as follows:
+//
+//	+----+----+----+----+----+----+----+----+----+----+....+----+
+//	| VN | CD | DSTPORT |      DSTIP        | USERID       |NULL|
+//	+----+----+----+----+----+----+----+----+----+----+....+----+
+//	|  1 |  1 |    2    |         4         |   Variable   |  1 |
+//	+----+----+----+----+----+----+----+----+----+----+....+----+
+//
+// SOCKS4a sets DSTIP to 0.0.0.x (x != 0) and appends the null-terminated DOMAIN after USERID.
+type Request4 struct {
+	// Version of socks protocol for message
+	Version byte
+	// Socks Command "connect","bind"
+	Command byte
+	// DstAddr in socks message
+	DstAddr AddrSpec
+	// UserID in socks message
+	UserID []byte
+}
+
+// NewRequest4 new socks4 request; use socks4a if the destination is a FQDN
+func NewRequest4(cmd byte, dest AddrSpec, userID []byte) Request4 {
+	return Request4{
+		Version: VersionSocks4,
+		Command: cmd,
+		DstAddr: dest,
+		UserID:  userID,
+	}
+}
+
+// ParseRequest4 parse socks4/socks4a request from io.Reader
+func ParseRequest4(r io.Reader) (req Request4, err error) {
+	// Read the version, command, port and ip
+	tmp := make([]byte, 8)
+	if _, err = io.ReadFull(r, tmp); err != nil {
+		return req, fmt.Errorf("failed to get request header, %v", err)
+	}
+	req.Version, req.Command = tmp[0], tmp[1]
+	if req.Version != VersionSocks4 {
+		return req, fmt.Errorf("unrecognized SOCKS version[%d]", req.Version)
+	}
+	req.DstAddr.Port = int(binary.BigEndian.Uint16(tmp[2:4]))
+	req.DstAddr.AddrType = ATYPIPv4
+	req.DstAddr.IP = net.IPv4(tmp[4], tmp[5], tmp[6], tmp[7])
+
+	if req.UserID, err = readNullTerminated(r); err != nil {
+		return req, fmt.Errorf("failed to get request userid, %w", err)
+	}
+	// SOCKS4a: 0.0.0.x
+	if tmp[4] == 0 && tmp[5] == 0 && tmp[6] == 0 && tmp[7] != 0 {
+		domain, dErr := readNullTerminated(r)
+		if dErr != nil {
+			return req, fmt.Errorf("failed to get request domain, %w", dErr)
+		}
+		req.DstAddr.AddrType, req.DstAddr.IP, req.DstAddr.FQDN = ATYPDomain, nil, string(domain)
+	}
+	return req, nil
+}
+
+// Bytes returns a slice of request
+func (h Request4) Bytes() []byte {
+	b := make([]byte, 0, 9+len(h.UserID)+len(h.DstAddr.FQDN)+1)
+	b = append(b, h.Version, h.Command, byte(h.DstAddr.Port>>8), byte(h.DstAddr.Port))
+	if h.DstAddr.AddrType == ATYPDomain {
+		b = append(b, 0, 0, 0, 1)
+	} else {
+		b = append(b, h.DstAddr.IP.To4()...)
+	}
+	b = append(b, h.UserID...)
+	b = append(b, 0)
+	if h.DstAddr.AddrType == ATYPDomain {
+		b = append(b, h.DstAddr.FQDN...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// Reply4 represents the SOCKS4 reply
+// The SOCKS4 reply is formed as follows:
+//
+//	+----+----+----+----+----+----+----+----+
+//	| VN | CD | DSTPORT |      DSTIP        |
+//	+----+----+----+----+----+----+----+----+
+//	|  1 |  1 |    2    |         4         |
+//	+----+----+----+----+----+----+----+----+
+type Reply4 struct {
+	// Version of reply, always 0
+	Version byte
+	// Socks Response status
+	Response byte
+	// Bind Address in socks message
+	BndAddr AddrSpec
+}
+
+// Bytes returns a slice of reply
+func (sf Reply4) Bytes() []byte {
+	b := make([]byte, 0, 8)
+	b = append(b, sf.Version, sf.Response, byte(sf.BndAddr.Port>>8), byte(sf.BndAddr.Port))
+	if ip4 := sf.BndAddr.IP.To4(); ip4 != nil {
+		b = append(b, ip4...)
+	} else {
+		b = append(b, 0, 0, 0, 0)
+	}
+	return b
+}
+
+// ParseReply4 parse socks4 reply from io.Reader
+func ParseReply4(r io.Reader) (rep Reply4, err error) {
+	tmp := make([]byte, 8)
+	if _, err = io.ReadFull(r, tmp); err != nil {
+		return rep, fmt.Errorf("failed to get reply, %v", err)
+	}
+	rep.Version, rep.Response = tmp[0], tmp[1]
+	if rep.Version != Reply4Version {
+		return rep, fmt.Errorf("unrecognized SOCKS4 reply version[%d]", rep.Version)
+	}
+	rep.BndAddr.AddrType = ATYPIPv4
+	rep.BndAddr.Port = int(binary.BigEndian.Uint16(tmp[2:4]))
+	rep.BndAddr.IP = net.IPv4(tmp[4], tmp[5], tmp[6], tmp[7])
+	return rep, nil
+}
+
+func readNullTerminated(r io.Reader) ([]byte, error) {
+	out := make([]byte, 0, 16)
+	tmp := []byte{0}
+	for {
+		if _, err := io.ReadFull(r, tmp); err != nil {
+			return nil, err
+		}
+		if tmp[0] == 0 {
+			return out, nil
+		}
+		if len(out) >= maxSocks4FieldLen {
+			return nil, ErrSocks4FieldTooLong
+		}
+		out = append(out, tmp[0])
+	}
+}
@@ -0,0 +1,85 @@
+package socks
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequest4(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Request4
+		wantErr bool
+	}{
+		{
+			"SOCKS4 IPV4",
+			[]byte{VersionSocks4, CommandConnect, 0x1f, 0x90, 127, 0, 0, 1, 'u', 's', 'e', 'r', 0},
+			Request4{
+				VersionSocks4, CommandConnect,
+				AddrSpec{IP: net.IPv4(127, 0, 0, 1), Port: 8080, AddrType: ATYPIPv4},
+				[]byte("user"),
+			},
+			false,
+		},
+		{
+			"SOCKS4a FQDN",
+			[]byte{VersionSocks4, CommandConnect, 0x1f, 0x90, 0, 0, 0, 1, 0, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0},
+			Request4{
+				VersionSocks4, CommandConnect,
+				AddrSpec{FQDN: "localhost", Port: 8080, AddrType: ATYPDomain},
+				[]byte{},
+			},
+			false,
+		},
+		{
+			"SOCKS4 invalid version",
+			[]byte{VersionSocks5, CommandConnect, 0x1f, 0x90, 127, 0, 0, 1, 0},
+			Request4{},
+			true,
+		},
+		{
+			"SOCKS4 missing userid terminator",
+			[]byte{VersionSocks4, CommandConnect, 0x1f, 0x90, 127, 0, 0, 1, 'u'},
+			Request4{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequest4(bytes.NewReader(tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.data, got.Bytes())
+		})
+	}
+}
+
+func TestParseRequest4TooLong(t *testing.T) {
+	data := append([]byte{VersionSocks4, CommandConnect, 0x1f, 0x90, 127, 0, 0, 1}, strings.Repeat("u", 300)...)
+	_, err := ParseRequest4(bytes.NewReader(append(data, 0)))
+	require.ErrorIs(t, err, ErrSocks4FieldTooLong)
+}
+
+func TestReply4(t *testing.T) {
+	rep := Reply4{
+		Version:  Reply4Version,
+		Response: Rep4Granted,
+		BndAddr:  AddrSpec{IP: net.IPv4(127, 0, 0, 1), Port: 8080, AddrType: ATYPIPv4},
+	}
+	want := []byte{Reply4Version, Rep4Granted, 0x1f, 0x90, 127, 0, 0, 1}
+	assert.Equal(t, want, rep.Bytes())
+
+	got, err := ParseReply4(bytes.NewReader(want))
+	require.NoError(t, err)
+	assert.Equal(t, rep, got)
+}
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"errors"
+	"net"
 )
 
 var (
@@ -14,4 +15,7 @@ type ListenerOptions struct {
 	Port    int
 	Verbose bool
 	Auth    bool
+	// ProxyProtocol 接收来自 ProxyProtocolTrusted 的 PROXY protocol(v1/v2) 首部
+	ProxyProtocol        bool
+	ProxyProtocolTrusted []*net.IPNet
 }